@@ -0,0 +1,330 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// Mode defines whether requests are recorded or replayed
+type Mode string
+
+// recorder modes
+const (
+	// ModeRecord sends every request and overwrites the cassette
+	ModeRecord Mode = "record"
+	// ModeReplay replays recorded responses
+	ModeReplay Mode = "replay"
+	// ModeAuto replays when the cassette file exists and records otherwise
+	ModeAuto Mode = "auto"
+)
+
+// scrubbedValue replaces values of scrubbed headers
+const scrubbedValue = "[SCRUBBED]"
+
+// base64Encoding marks bodies which are not valid UTF-8
+const base64Encoding = "base64"
+
+// ErrUnmatched is returned in strict mode for requests matching no interaction
+var ErrUnmatched = errors.New("request matches no recorded interaction")
+
+// Request defines a recorded request
+type Request struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// Response defines a recorded response
+type Response struct {
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// Interaction defines a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	replayed bool
+}
+
+// Cassette defines the recorded interactions of a file
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Config defines recorder behavior
+type Config struct {
+	Mode Mode
+	// Matchers must all match for a recorded interaction to be replayed
+	Matchers []Matcher
+	// ScrubHeaders are recorded with scrubbed values
+	ScrubHeaders []string
+	// Scrub modifies interactions before they are saved, e.g. to remove secrets of
+	// bodies
+	Scrub func(*Interaction)
+	// Strict fails requests matching no interaction, otherwise they are sent
+	Strict bool
+	// AllowRepeats replays an interaction more than once
+	AllowRepeats bool
+}
+
+// DefaultConfig returns config of strict auto mode matching method and URL, with
+// credential headers scrubbed
+func DefaultConfig() *Config {
+	return &Config{
+		Mode:         ModeAuto,
+		Matchers:     []Matcher{MatchMethod, MatchURL},
+		ScrubHeaders: []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+		Strict:       true,
+	}
+}
+
+// Recorder records and replays interactions of a cassette file
+type Recorder struct {
+	Config   *Config
+	path     string
+	mode     Mode
+	cassette *Cassette
+	mux      sync.Mutex
+}
+
+// New creates recorder of cassette file path, nil config uses DefaultConfig. The file
+// is loaded unless it is recorded
+func New(path string, config *Config) (*Recorder, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	mode := config.Mode
+	if mode == ModeAuto {
+		mode = ModeReplay
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			mode = ModeRecord
+		}
+	}
+	r := &Recorder{Config: config, path: path, mode: mode, cassette: &Cassette{}}
+	switch mode {
+	case ModeRecord:
+		return r, nil
+	case ModeReplay:
+	default:
+		return nil, errors.New(lib.StringTags("new recorder", "unknown mode", string(mode)))
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("new recorder", "read cassette"))
+	}
+	if err = json.Unmarshal(data, r.cassette); err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("new recorder", "decode cassette", path))
+	}
+	return r, nil
+}
+
+// Mode returns whether the recorder records or replays
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Middleware records or replays requests instead of sending them by next, it is used
+// as the last http.Client middleware
+func (r *Recorder) Middleware(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return r.roundTrip(req, next)
+	})
+}
+
+// Transport returns round tripper recording requests sent by next, nil next uses
+// http.DefaultTransport
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return r.Middleware(next)
+}
+
+// Unplayed returns interactions which are not replayed yet
+func (r *Recorder) Unplayed() []*Interaction {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var interactions []*Interaction
+	for _, interaction := range r.cassette.Interactions {
+		if !interaction.replayed {
+			interactions = append(interactions, interaction)
+		}
+	}
+	return interactions
+}
+
+// Stop saves recorded interactions to the cassette file
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("stop recorder", "encode cassette"))
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return errors.Wrap(err, lib.StringTags("stop recorder", "create directory"))
+	}
+	return errors.Wrap(ioutil.WriteFile(r.path, data, 0644), lib.StringTags("stop recorder", "write cassette"))
+}
+
+func (r *Recorder) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	req, body, err := readRequestBody(req)
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("cassette", "read request body"))
+	}
+	if r.mode == ModeReplay {
+		if interaction := r.match(req, body); interaction != nil {
+			return interaction.Response.toHTTP(req)
+		}
+		if r.Config.Strict {
+			return nil, errors.Wrap(ErrUnmatched, lib.StringTags("cassette", req.Method, req.URL.String()))
+		}
+		return next.RoundTrip(req)
+	}
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("cassette", "read response body"))
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.scrub(req.Header),
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     r.scrub(res.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(body)
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(resBody)
+	if r.Config.Scrub != nil {
+		r.Config.Scrub(interaction)
+	}
+	r.mux.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mux.Unlock()
+	return res, nil
+}
+
+// match returns the first interaction matching the request which is not replayed, it
+// is marked replayed
+func (r *Recorder) match(req *http.Request, body []byte) *Interaction {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, interaction := range r.cassette.Interactions {
+		if interaction.replayed && !r.Config.AllowRepeats {
+			continue
+		}
+		matched := true
+		for _, matcher := range r.Config.Matchers {
+			if !matcher(req, body, &interaction.Request) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			interaction.replayed = true
+			return interaction
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) scrub(header http.Header) http.Header {
+	scrubbed := make(http.Header, len(header))
+	for key, values := range header {
+		scrubbed[key] = append([]string(nil), values...)
+		for _, name := range r.Config.ScrubHeaders {
+			if strings.EqualFold(name, key) {
+				scrubbed[key] = []string{scrubbedValue}
+				break
+			}
+		}
+	}
+	return scrubbed
+}
+
+// toHTTP creates the recorded response of req
+func (res *Response) toHTTP(req *http.Request) (*http.Response, error) {
+	body, err := decodeBody(res.Body, res.BodyEncoding)
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("cassette", "decode response body"))
+	}
+	header := make(http.Header, len(res.Header))
+	for key, values := range res.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads body of req, the returned copy of req sends the body
+func readRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return req, body, nil
+}
+
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), base64Encoding
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == base64Encoding {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,124 @@
+package cassette
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	gomHTTP "github.com/hauxe/gom/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+	var sent int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0x00, 0xfe})
+			return
+		}
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(body)))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "cassette")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixtures", "downstream.json")
+	config := DefaultConfig()
+	config.Matchers = append(config.Matchers, MatchBody)
+	ctx := context.Background()
+
+	newClient := func() (*Recorder, *gomHTTP.Client) {
+		recorder, err := New(path, config)
+		require.Nil(t, err)
+		client, err := gomHTTP.CreateClient()
+		require.Nil(t, err)
+		require.Nil(t, client.Connect(client.SetMiddlewareOption(recorder.Middleware)))
+		return recorder, client
+	}
+	send := func(client *gomHTTP.Client, method, path string, body interface{}) (string, error) {
+		options := []gomHTTP.SendClientOptions{client.SetRequestOptionHeader(map[string]interface{}{
+			"Authorization": "Bearer secret",
+		})}
+		if body != nil {
+			options = append(options, client.SetRequestOptionJSON(body))
+		}
+		resp, err := client.Send(ctx, method, server.URL+path, options...)
+		if err != nil {
+			return "", err
+		}
+		return gomHTTP.ReadBodyString(resp)
+	}
+
+	// cassette does not exist, requests are recorded
+	recorder, client := newClient()
+	require.Equal(t, ModeRecord, recorder.Mode())
+	body, err := send(client, http.MethodPost, "/items?page=1", map[string]int{"a": 1, "b": 2})
+	require.Nil(t, err)
+	require.Equal(t, `POST /items?page=1 {"a":1,"b":2}`, body)
+	_, err = send(client, http.MethodGet, "/binary", nil)
+	require.Nil(t, err)
+	require.Nil(t, recorder.Stop())
+	require.Equal(t, int32(2), atomic.LoadInt32(&sent))
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.NotContains(t, string(data), "secret")
+	require.Contains(t, string(data), scrubbedValue)
+
+	// cassette exists, requests are replayed without being sent
+	recorder, client = newClient()
+	require.Equal(t, ModeReplay, recorder.Mode())
+	_, err = send(client, http.MethodPost, "/items?page=1", map[string]int{"b": 2, "a": 1})
+	require.Nil(t, err)
+	body, err = send(client, http.MethodGet, "/binary", nil)
+	require.Nil(t, err)
+	require.Equal(t, string([]byte{0xff, 0x00, 0xfe}), body)
+	require.Empty(t, recorder.Unplayed())
+	require.Equal(t, int32(2), atomic.LoadInt32(&sent))
+
+	// replayed interactions and unmatched requests fail in strict mode
+	_, err = send(client, http.MethodGet, "/binary", nil)
+	require.NotNil(t, err)
+	require.True(t, strings.Contains(err.Error(), ErrUnmatched.Error()))
+	_, err = send(client, http.MethodPost, "/items?page=1", map[string]int{"a": 3})
+	require.NotNil(t, err)
+
+	// unmatched requests are sent when not strict
+	config.Strict = false
+	config.AllowRepeats = true
+	recorder, client = newClient()
+	_, err = send(client, http.MethodGet, "/binary", nil)
+	require.Nil(t, err)
+	_, err = send(client, http.MethodGet, "/binary", nil)
+	require.Nil(t, err)
+	body, err = send(client, http.MethodGet, "/other", nil)
+	require.Nil(t, err)
+	require.Equal(t, "GET /other ", body)
+	require.Equal(t, int32(3), atomic.LoadInt32(&sent))
+	require.Len(t, recorder.Unplayed(), 1)
+	require.Nil(t, recorder.Stop())
+
+	_, err = New(path, &Config{Mode: "unknown"})
+	require.NotNil(t, err)
+	_, err = New(filepath.Join(dir, "missing.json"), &Config{Mode: ModeReplay})
+	require.NotNil(t, err)
+}
+
+func TestMatchHeaders(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Tenant", "a")
+	match := MatchHeaders("x-tenant")
+	require.True(t, match(r, nil, &Request{Header: http.Header{"X-Tenant": {"a"}}}))
+	require.False(t, match(r, nil, &Request{Header: http.Header{"X-Tenant": {"b"}}}))
+	require.False(t, MatchBody(r, []byte("plain"), &Request{Body: "other"}))
+}
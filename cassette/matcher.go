@@ -0,0 +1,52 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// Matcher reports whether request with body matches the recorded request
+type Matcher func(req *http.Request, body []byte, recorded *Request) bool
+
+// MatchMethod matches request method
+func MatchMethod(req *http.Request, _ []byte, recorded *Request) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL matches request URL
+func MatchURL(req *http.Request, _ []byte, recorded *Request) bool {
+	return req.URL.String() == recorded.URL
+}
+
+// MatchBody matches request body, JSON bodies match when they are equal values
+// regardless of formatting and key order
+func MatchBody(_ *http.Request, body []byte, recorded *Request) bool {
+	recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	if err != nil {
+		return false
+	}
+	if bytes.Equal(body, recordedBody) {
+		return true
+	}
+	var value, recordedValue interface{}
+	if json.Unmarshal(body, &value) != nil || json.Unmarshal(recordedBody, &recordedValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(value, recordedValue)
+}
+
+// MatchHeaders returns matcher of request header values of names, scrubbed headers
+// should not be matched
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, _ []byte, recorded *Request) bool {
+		for _, name := range names {
+			if !reflect.DeepEqual(req.Header[http.CanonicalHeaderKey(name)],
+				recorded.Header[http.CanonicalHeaderKey(name)]) {
+				return false
+			}
+		}
+		return true
+	}
+}
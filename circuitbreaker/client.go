@@ -23,8 +23,10 @@ type StartClientOptions func(*circuit.Config, *hystrix.ConfigureCloser, *hystrix
 
 // Client defines circuit breaker client properties
 type Client struct {
-	manager *circuit.Manager
-	Logger  sdklog.Factory
+	manager      *circuit.Manager
+	Logger       sdklog.Factory
+	autoStart    bool
+	startOptions []StartClientOptions
 }
 
 // CreateClient create new circuit client
@@ -81,6 +83,13 @@ func (c *Client) Start(name string, options ...StartClientOptions) error {
 	return nil
 }
 
+// SetAutoStart starts missing circuits with options when they are executed, e.g.
+// circuits named by request hosts
+func (c *Client) SetAutoStart(options ...StartClientOptions) {
+	c.autoStart = true
+	c.startOptions = options
+}
+
 // SetExecuteTimeoutOption set execution timeout config
 func (c *Client) SetExecuteTimeoutOption(timeout time.Duration) StartClientOptions {
 	return func(config *circuit.Config, _ *hystrix.ConfigureCloser, _ *hystrix.ConfigureOpener) error {
@@ -181,6 +190,12 @@ func (c *Client) SetOpenerNumBuckets(buckets int) StartClientOptions {
 func (c *Client) Execute(ctx context.Context, name string,
 	runFunc func(context.Context) error, fallbackFunc func(context.Context, error) error) error {
 	circuitbreaker := c.manager.GetCircuit(name)
+	if circuitbreaker == nil && c.autoStart {
+		if err := c.Start(name, c.startOptions...); err != nil {
+			return errors.Wrap(err, lib.StringTags("execute", "start circuit", name))
+		}
+		circuitbreaker = c.manager.GetCircuit(name)
+	}
 	if circuitbreaker == nil {
 		return errors.Errorf("no circuit match name %s", name)
 	}
@@ -123,3 +123,14 @@ func TestExecute(t *testing.T) {
 		require.NotNil(t, err)
 	})
 }
+
+func TestExecuteAutoStart(t *testing.T) {
+	t.Parallel()
+	client, err := CreateClient()
+	require.Nil(t, err)
+	run := func(context.Context) error { return nil }
+	require.NotNil(t, client.Execute(context.Background(), "example.com", run, nil))
+	client.SetAutoStart(client.SetOpenerRequestThreshold(20))
+	require.Nil(t, client.Execute(context.Background(), "example.com", run, nil))
+	require.Nil(t, client.Execute(context.Background(), "example.com", run, nil))
+}
@@ -1,7 +1,7 @@
 package crudl
 
 import (
-	"net/http"
+	"context"
 
 	gomHTTP "github.com/hauxe/gom/http"
 )
@@ -13,152 +13,72 @@ type response struct {
 	data         map[string]interface{}
 }
 
-func (crud *CRUD) handleCreate(w http.ResponseWriter, r *http.Request) {
-	obj := crud.Config.Object.Get()
+type listRequest struct {
+	PageID  int64 `json:"page_id" schema:"page_id,required"`
+	PerPage int64 `json:"per_page" schema:"per_page,required"`
+}
 
-	err := gomHTTP.ParseParameters(r, obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
-		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
-		}
-		return
-	}
-	err = crud.Create(obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
+func newListRequest() interface{} {
+	return &listRequest{}
+}
+
+// logError logs errors of crud operations before they are sent
+func (crud *CRUD) logError(handle gomHTTP.HandleFunc) gomHTTP.HandleFunc {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		resp, err := handle(ctx, req)
 		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
+			crud.Logger.For(ctx).Error(err.Error())
 		}
+		return resp, err
 	}
+}
 
-	err = gomHTTP.SendResponse(w, http.StatusOK, gomHTTP.ErrorCodeSuccess, "created successfully", map[string]interface{}{
-		"success": obj,
+func (crud *CRUD) handleCreate() gomHTTP.HandleFunc {
+	return crud.logError(func(_ context.Context, obj interface{}) (interface{}, error) {
+		if err := crud.Create(obj); err != nil {
+			return nil, err
+		}
+		return obj, nil
 	})
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-	}
 }
 
-func (crud *CRUD) handleRead(w http.ResponseWriter, r *http.Request) {
-	obj := crud.Config.Object.Get()
-
-	err := gomHTTP.ParseParameters(r, obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
+func (crud *CRUD) handleRead() gomHTTP.HandleFunc {
+	return crud.logError(func(_ context.Context, obj interface{}) (interface{}, error) {
+		row, err := crud.Read(obj)
 		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
+			return nil, err
 		}
-		return
-	}
-	row, err := crud.Read(obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
-		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
-		}
-	}
-
-	err = gomHTTP.SendResponse(w, http.StatusOK, gomHTTP.ErrorCodeSuccess, "created successfully", map[string]interface{}{
-		"success": row,
+		return row, nil
 	})
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-	}
 }
 
-func (crud *CRUD) handleUpdate(w http.ResponseWriter, r *http.Request) {
-	obj := crud.Config.Object.Get()
-
-	err := gomHTTP.ParseParameters(r, obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
-		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
-		}
-		return
-	}
-	err = crud.Update(obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
-		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
+func (crud *CRUD) handleUpdate() gomHTTP.HandleFunc {
+	return crud.logError(func(_ context.Context, obj interface{}) (interface{}, error) {
+		if err := crud.Update(obj); err != nil {
+			return nil, err
 		}
-	}
-
-	err = gomHTTP.SendResponse(w, http.StatusOK, gomHTTP.ErrorCodeSuccess, "updated successfully", map[string]interface{}{
-		"success": obj,
+		return obj, nil
 	})
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-	}
 }
 
-func (crud *CRUD) handleDelete(w http.ResponseWriter, r *http.Request) {
-	obj := crud.Config.Object.Get()
-
-	err := gomHTTP.ParseParameters(r, obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
+func (crud *CRUD) handleDelete() gomHTTP.HandleFunc {
+	return crud.logError(func(_ context.Context, obj interface{}) (interface{}, error) {
+		row, err := crud.Delete(obj)
 		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
+			return nil, err
 		}
-		return
-	}
-	row, err := crud.Delete(obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
-		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
-		}
-		return
-	}
-
-	err = gomHTTP.SendResponse(w, http.StatusOK, gomHTTP.ErrorCodeSuccess, "created successfully", map[string]interface{}{
-		"success": row,
+		return row, nil
 	})
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-	}
 }
 
 // handleList handle request for getting list data
-func (crud *CRUD) handleList(w http.ResponseWriter, r *http.Request) {
-	obj := struct {
-		PageID  int64 `json:"page_id" schema:"page_id,required"`
-		PerPage int64 `json:"per_page" schema:"per_page,required"`
-	}{}
-
-	err := gomHTTP.ParseParameters(r, &obj)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
+func (crud *CRUD) handleList() gomHTTP.HandleFunc {
+	return crud.logError(func(_ context.Context, req interface{}) (interface{}, error) {
+		list := req.(*listRequest)
+		l, err := crud.List(list.PageID, list.PerPage)
 		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
+			return nil, err
 		}
-		return
-	}
-	l, err := crud.List(obj.PageID, obj.PerPage)
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-		err = gomHTTP.SendError(w, err)
-		if err != nil {
-			crud.Logger.For(r.Context()).Error(err.Error())
-		}
-	}
-
-	err = gomHTTP.SendResponse(w, http.StatusOK, gomHTTP.ErrorCodeSuccess, "updated successfully", map[string]interface{}{
-		"success": l,
+		return l, nil
 	})
-	if err != nil {
-		crud.Logger.For(r.Context()).Error(err.Error())
-	}
 }
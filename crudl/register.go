@@ -163,7 +163,9 @@ func (crud *CRUD) registerC() gomHTTP.ServerRoute {
 		Method:     http.MethodPost,
 		Path:       fmt.Sprintf("/%s", crud.Config.TableName),
 		Validators: validators,
-		Handler:    crud.handleCreate,
+		Handler:    gomHTTP.HandleMessage(crud.Config.Object.Get, "created successfully", crud.handleCreate()),
+		Request:    crud.Config.Object.Get(),
+		Response:   crud.Config.Object.Get(),
 	}
 }
 
@@ -186,7 +188,9 @@ func (crud *CRUD) registerR() gomHTTP.ServerRoute {
 		Method:     http.MethodGet,
 		Path:       fmt.Sprintf("/%s", crud.Config.TableName),
 		Validators: []gomHTTP.ParamValidator{validatePrimaryKey(crud.Config.pk.index)},
-		Handler:    crud.handleRead,
+		Handler:    gomHTTP.HandleMessage(crud.Config.Object.Get, "created successfully", crud.handleRead()),
+		Request:    crud.Config.Object.Get(),
+		Response:   crud.Config.Object.Get(),
 	}
 }
 
@@ -222,7 +226,9 @@ func (crud *CRUD) registerU() gomHTTP.ServerRoute {
 		Method:     http.MethodPatch,
 		Path:       fmt.Sprintf("/%s", crud.Config.TableName),
 		Validators: validators,
-		Handler:    crud.handleUpdate,
+		Handler:    gomHTTP.HandleMessage(crud.Config.Object.Get, "updated successfully", crud.handleUpdate()),
+		Request:    crud.Config.Object.Get(),
+		Response:   crud.Config.Object.Get(),
 	}
 }
 
@@ -235,7 +241,9 @@ func (crud *CRUD) registerD() gomHTTP.ServerRoute {
 		Method:     http.MethodDelete,
 		Path:       fmt.Sprintf("/%s", crud.Config.TableName),
 		Validators: []gomHTTP.ParamValidator{validatePrimaryKey(crud.Config.pk.index)},
-		Handler:    crud.handleDelete,
+		Handler:    gomHTTP.HandleMessage(crud.Config.Object.Get, "created successfully", crud.handleDelete()),
+		Request:    crud.Config.Object.Get(),
+		Response:   int64(0),
 	}
 }
 
@@ -255,9 +263,11 @@ func (crud *CRUD) registerL() gomHTTP.ServerRoute {
 		crud.Config.TableName, crud.Config.pk.name)
 	fmt.Println(crud.Config.sqlCRUDList)
 	return gomHTTP.ServerRoute{
-		Name:    "crud_list_" + crud.Config.TableName,
-		Method:  http.MethodGet,
-		Path:    fmt.Sprintf("/%s/list", crud.Config.TableName),
-		Handler: crud.handleList,
+		Name:     "crud_list_" + crud.Config.TableName,
+		Method:   http.MethodGet,
+		Path:     fmt.Sprintf("/%s/list", crud.Config.TableName),
+		Handler:  gomHTTP.HandleMessage(newListRequest, "updated successfully", crud.handleList()),
+		Request:  listRequest{},
+		Response: reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(crud.Config.Object.Get())), 0, 0).Interface(),
 	}
 }
@@ -238,6 +238,22 @@ func (e *ENV) getFieldENV(fieldName string, field reflect.Value, name string) er
 		}
 		field.SetBool(b)
 		return nil
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			break
+		}
+		// string slice is separated by comma
+		values := strings.Split(s, ",")
+		slice := reflect.MakeSlice(field.Type(), 0, len(values))
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			slice = reflect.Append(slice, reflect.ValueOf(value).Convert(field.Type().Elem()))
+		}
+		field.Set(slice)
+		return nil
 	}
 	return errors.Errorf("convert field %s, type %s not supported",
 		fieldName, field.Kind().String())
@@ -594,6 +594,24 @@ func TestGetFieldENV(t *testing.T) {
 		require.Error(t, err)
 		require.Equal(t, -1, clone.A)
 	})
+	t.Run("string_slice_parse_env_success", func(t *testing.T) {
+		t.Parallel()
+		clone := struct{ H []string }{[]string{"default"}}
+		rv := reflect.ValueOf(&clone)
+		os.Setenv(prefix+t.Name(), "a, b,,c ")
+		err := env.getFieldENV("H", rv.Elem().FieldByName("H"), prefix+t.Name())
+		require.Nil(t, err)
+		require.Equal(t, []string{"a", "b", "c"}, clone.H)
+	})
+	t.Run("slice_type_not_supported", func(t *testing.T) {
+		t.Parallel()
+		clone := struct{ I []int }{}
+		rv := reflect.ValueOf(&clone)
+		os.Setenv(prefix+t.Name(), "1,2")
+		err := env.getFieldENV("I", rv.Elem().FieldByName("I"), prefix+t.Name())
+		require.Error(t, err)
+		require.Empty(t, clone.I)
+	})
 	testCases := []struct {
 		Name      string
 		FieldName string
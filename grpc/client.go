@@ -1,6 +1,8 @@
 package grpc
 
 import (
+	"context"
+
 	"github.com/pkg/errors"
 
 	lib "github.com/hauxe/gom/library"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	g "google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 const (
@@ -33,6 +36,8 @@ type Client struct {
 	Logger      sdklog.Factory
 	TraceClient *trace.Client
 	DialOptions []g.DialOption
+	// UnaryInterceptors are chained into one dial option when client connects
+	UnaryInterceptors []g.UnaryClientInterceptor
 }
 
 // CreateClient creates GRPC client
@@ -60,7 +65,11 @@ func (c *Client) Connect(options ...StartClientOptions) (err error) {
 		}
 	}
 	url := lib.GetURL(c.Config.Host, c.Config.Port)
-	c.C, err = g.Dial(url, c.DialOptions...)
+	dialOptions := c.DialOptions
+	if len(c.UnaryInterceptors) > 0 {
+		dialOptions = append(dialOptions, g.WithUnaryInterceptor(ChainUnaryClient(c.UnaryInterceptors...)))
+	}
+	c.C, err = g.Dial(url, dialOptions...)
 
 	return err
 }
@@ -73,6 +82,18 @@ func (c *Client) Disconnect() error {
 	return nil
 }
 
+// HealthCheck checks grpc connection state
+func (c *Client) HealthCheck(_ context.Context) error {
+	if c.C == nil {
+		return errors.New(lib.StringTags("health check", "client is not connected"))
+	}
+	switch state := c.C.GetState(); state {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return errors.New(lib.StringTags("health check", "connection state "+state.String()))
+	}
+	return nil
+}
+
 // SetHostPortOption set client host port
 func (c *Client) SetHostPortOption(host string, port int) StartClientOptions {
 	return func() (err error) {
@@ -96,8 +117,16 @@ func (c *Client) SetMiddlewareTracerOption() StartClientOptions {
 		if c.TraceClient == nil {
 			return errors.New("option SetTracerOption must be set first")
 		}
-		dialOption := g.WithUnaryInterceptor(otgrpc.OpenTracingClientInterceptor(c.TraceClient.Tracer))
-		c.DialOptions = append(c.DialOptions, dialOption)
+		c.UnaryInterceptors = append(c.UnaryInterceptors,
+			otgrpc.OpenTracingClientInterceptor(c.TraceClient.Tracer))
+		return nil
+	}
+}
+
+// SetMiddlewareRequestIDOption set grpc request id middleware
+func (c *Client) SetMiddlewareRequestIDOption() StartClientOptions {
+	return func() error {
+		c.UnaryInterceptors = append(c.UnaryInterceptors, RequestIDClientInterceptor)
 		return nil
 	}
 }
@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	lib "github.com/hauxe/gom/library"
+	g "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// MetadataRequestID is the metadata key carrying request id
+var MetadataRequestID = strings.ToLower(lib.HeaderRequestID)
+
+// ChainUnaryServer composes server interceptors into one since grpc accepts only
+// one, the first interceptor is the outermost
+func ChainUnaryServer(interceptors ...g.UnaryServerInterceptor) g.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *g.UnaryServerInfo,
+		handler g.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ChainUnaryClient composes client interceptors into one, the first interceptor
+// is the outermost
+func ChainUnaryClient(interceptors ...g.UnaryClientInterceptor) g.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *g.ClientConn, invoker g.UnaryInvoker, opts ...g.CallOption) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], invoker
+			invoker = func(ctx context.Context, method string, req, reply interface{},
+				cc *g.ClientConn, opts ...g.CallOption) error {
+				return interceptor(ctx, method, req, reply, cc, next, opts...)
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// RequestIDServerInterceptor accepts the caller request id from metadata or generates
+// one, stores it in the context and sends it back in the response header
+func RequestIDServerInterceptor(ctx context.Context, req interface{}, _ *g.UnaryServerInfo,
+	handler g.UnaryHandler) (interface{}, error) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataRequestID); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if !lib.ValidRequestID(requestID) {
+		requestID = lib.NewRequestID()
+	}
+	ctx = lib.ContextWithRequestID(ctx, requestID)
+	// header is best effort, the response is still valid without it
+	g.SetHeader(ctx, metadata.Pairs(MetadataRequestID, requestID))
+	return handler(ctx, req)
+}
+
+// RequestIDClientInterceptor forwards the context request id in metadata
+func RequestIDClientInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *g.ClientConn, invoker g.UnaryInvoker, opts ...g.CallOption) error {
+	if requestID := lib.RequestIDFromContext(ctx); requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataRequestID, requestID)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// ClientIdentityServerInterceptor stores identity of the verified client certificate
+// in the context
+func ClientIdentityServerInterceptor(ctx context.Context, req interface{}, _ *g.UnaryServerInfo,
+	handler g.UnaryHandler) (interface{}, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if identity := lib.ClientIdentityOf(&info.State); identity != nil {
+				ctx = lib.ContextWithClientIdentity(ctx, identity)
+			}
+		}
+	}
+	return handler(ctx, req)
+}
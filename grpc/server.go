@@ -17,6 +17,7 @@ import (
 	"github.com/hauxe/gom/pool"
 	"github.com/hauxe/gom/trace"
 	g "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -40,6 +41,27 @@ type ServerConfig struct {
 	Port                   int    `env:"GRPC_SERVER_PORT"`
 	EnableServiceDiscovery bool   `env:"GRPC_SERVER_SERVICE_DISCOVERY"`
 	ReadTimeout            int    `env:"GRPC_SERVER_READ_TIMEOUT"`
+	// server certificate and client certificate authentication, reload interval is
+	// in seconds
+	ServeTLS              bool     `env:"GRPC_SERVER_TLS"`
+	CertFile              string   `env:"GRPC_SERVER_CERT"`
+	KeyFile               string   `env:"GRPC_SERVER_KEY"`
+	ClientCAFile          string   `env:"GRPC_SERVER_CLIENT_CA"`
+	ClientAuth            string   `env:"GRPC_SERVER_CLIENT_AUTH"`
+	AllowedClientSubjects []string `env:"GRPC_SERVER_CLIENT_SUBJECTS"`
+	CertReloadInterval    int      `env:"GRPC_SERVER_CERT_RELOAD_INTERVAL"`
+}
+
+// TLSConfig returns tls config of the server certificate and client authentication
+func (c *ServerConfig) TLSConfig() *lib.TLSConfig {
+	return &lib.TLSConfig{
+		CertFile:        c.CertFile,
+		KeyFile:         c.KeyFile,
+		ReloadInterval:  time.Duration(c.CertReloadInterval) * time.Second,
+		ClientCAFile:    c.ClientCAFile,
+		ClientAuth:      c.ClientAuth,
+		AllowedSubjects: c.AllowedClientSubjects,
+	}
 }
 
 // Server defines GRPC server properties
@@ -50,7 +72,11 @@ type Server struct {
 	Logger        sdklog.Factory
 	TraceClient   *trace.Client
 	ServerOptions []g.ServerOption
-	WorkerPools   []*pool.Worker
+	// UnaryInterceptors are chained into one server option when server starts
+	UnaryInterceptors []g.UnaryServerInterceptor
+	WorkerPools       []*pool.Worker
+	tlsConfig         *lib.TLSConfig
+	certReloader      *lib.CertificateReloader
 }
 
 // CreateServer creates GRPC server
@@ -59,7 +85,12 @@ func CreateServer(options ...environment.CreateENVOptions) (server *Server, err
 	if err != nil {
 		return nil, errors.Wrap(err, lib.StringTags("create server", "create env"))
 	}
-	config := ServerConfig{serverHost, serverPort, enableServiceDisconvery, readTimeout}
+	config := ServerConfig{
+		Host:                   serverHost,
+		Port:                   serverPort,
+		EnableServiceDiscovery: enableServiceDisconvery,
+		ReadTimeout:            readTimeout,
+	}
 	if err = env.Parse(&config); err != nil {
 		return nil, errors.Wrap(err, lib.StringTags("create server", "parse env"))
 	}
@@ -80,7 +111,32 @@ func (s *Server) Start(services []RegisterService, options ...StartServerOptions
 			return errors.Wrap(err, lib.StringTags("start server", "option error"))
 		}
 	}
-	s.S = g.NewServer(s.ServerOptions...)
+	serverOptions := s.ServerOptions
+	interceptors := s.UnaryInterceptors
+	if s.Config.ServeTLS {
+		config := s.Config.TLSConfig()
+		if s.tlsConfig != nil {
+			copied := *s.tlsConfig
+			config = &copied
+		}
+		if config.OnReloadError == nil {
+			config.OnReloadError = func(err error) {
+				s.Logger.Bg().Error(fmt.Sprintf("reload certificate: %v", err))
+			}
+		}
+		tlsConfig, reloader, err := lib.NewServerTLSConfig(config)
+		if err != nil {
+			return errors.Wrap(err, lib.StringTags("start server", "tls"))
+		}
+		s.certReloader = reloader
+		serverOptions = append(serverOptions, g.Creds(credentials.NewTLS(tlsConfig)))
+		interceptors = append([]g.UnaryServerInterceptor{ClientIdentityServerInterceptor},
+			interceptors...)
+	}
+	if len(interceptors) > 0 {
+		serverOptions = append(serverOptions, g.UnaryInterceptor(ChainUnaryServer(interceptors...)))
+	}
+	s.S = g.NewServer(serverOptions...)
 	for _, srv := range services {
 		if err = srv(s.S); err != nil {
 			return errors.Wrap(err, lib.StringTags("start server", "register service error"))
@@ -114,6 +170,9 @@ func (s *Server) Stop() error {
 	if s.S != nil {
 		s.S.Stop()
 	}
+	if s.certReloader != nil {
+		s.certReloader.Close()
+	}
 	if s.Conn != nil {
 		return s.Conn.Close()
 	}
@@ -131,6 +190,16 @@ func (s *Server) SetServiceDiscoveryOption(val bool) StartServerOptions {
 	}
 }
 
+// SetTLSConfigOption set grpc server serves TLS with certificate reloading and client
+// authentication of config instead of the server config
+func (s *Server) SetTLSConfigOption(config *lib.TLSConfig) StartServerOptions {
+	return func() (err error) {
+		s.Config.ServeTLS = true
+		s.tlsConfig = config
+		return nil
+	}
+}
+
 // SetTracerOption set tracer
 func (s *Server) SetTracerOption(tracer *trace.Client) StartServerOptions {
 	return func() (err error) {
@@ -145,8 +214,16 @@ func (s *Server) SetMiddlewareTracerOption() StartServerOptions {
 		if s.TraceClient == nil {
 			return errors.New("option SetTracerOption must be set first")
 		}
-		serverOption := g.UnaryInterceptor(otgrpc.OpenTracingServerInterceptor(s.TraceClient.Tracer))
-		s.ServerOptions = append(s.ServerOptions, serverOption)
+		s.UnaryInterceptors = append(s.UnaryInterceptors,
+			otgrpc.OpenTracingServerInterceptor(s.TraceClient.Tracer))
+		return nil
+	}
+}
+
+// SetMiddlewareRequestIDOption set grpc request id middleware
+func (s *Server) SetMiddlewareRequestIDOption() StartServerOptions {
+	return func() error {
+		s.UnaryInterceptors = append(s.UnaryInterceptors, RequestIDServerInterceptor)
 		return nil
 	}
 }
@@ -179,8 +256,7 @@ func (s *Server) SetMiddlewarePoolWorkerOption(maxWorkers int) StartServerOption
 			s.Logger.Bg().Info(fmt.Sprintf("%#v", result))
 			return result.resp, result.err
 		}
-		serverOption := g.UnaryInterceptor(interceptor)
-		s.ServerOptions = append(s.ServerOptions, serverOption)
+		s.UnaryInterceptors = append(s.UnaryInterceptors, interceptor)
 		return nil
 	}
 }
@@ -1,11 +1,18 @@
 package grpc
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"testing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
+	lib "github.com/hauxe/gom/library"
 	"github.com/stretchr/testify/require"
 	context "golang.org/x/net/context"
 )
@@ -13,7 +20,13 @@ import (
 type test struct {
 }
 
-func (t *test) Test(_ context.Context, req *Request) (*Response, error) {
+func (t *test) Test(ctx context.Context, req *Request) (*Response, error) {
+	if req.Name == "request_id" {
+		return &Response{
+			Code:    1,
+			Content: lib.RequestIDFromContext(ctx),
+		}, nil
+	}
 	if req.Name == "error" {
 		return &Response{
 			Code:    -1,
@@ -66,3 +79,56 @@ func TestServer(t *testing.T) {
 	require.Error(t, err)
 	require.Nil(t, resp)
 }
+
+func TestServerRequestID(t *testing.T) {
+	t.Parallel()
+	testSrv := test{}
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	server.Config.Port = 10001
+	// request id runs outside the worker pool so both interceptors are chained
+	require.Nil(t, server.Start([]RegisterService{
+		func(s *grpc.Server) error {
+			RegisterTestSrvServer(s, &testSrv)
+			return nil
+		},
+	}, server.SetMiddlewareRequestIDOption(), server.SetMiddlewarePoolWorkerOption(2)))
+	defer server.Stop()
+
+	client, err := CreateClient()
+	require.Nil(t, err)
+	require.Nil(t, client.Connect(client.SetHostPortOption(server.Config.Host, server.Config.Port),
+		client.SetMiddlewareRequestIDOption()))
+	defer client.Disconnect()
+	testClient := NewTestSrvClient(client.C)
+
+	ctx := lib.ContextWithRequestID(context.Background(), "grpc-request-id")
+	header := metadata.MD{}
+	resp, err := testClient.Test(ctx, &Request{Name: "request_id"}, grpc.Header(&header))
+	require.Nil(t, err)
+	require.Equal(t, "grpc-request-id", resp.Content)
+	require.Equal(t, []string{"grpc-request-id"}, header[MetadataRequestID])
+
+	resp, err = testClient.Test(context.Background(), &Request{Name: "request_id"})
+	require.Nil(t, err)
+	require.True(t, lib.ValidRequestID(resp.Content))
+}
+
+func TestClientIdentityServerInterceptor(t *testing.T) {
+	t.Parallel()
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return lib.ClientIdentityFromContext(ctx), nil
+	}
+	identity, err := ClientIdentityServerInterceptor(context.Background(), nil, nil, handler)
+	require.Nil(t, err)
+	require.Nil(t, identity)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+	identity, err = ClientIdentityServerInterceptor(ctx, nil, nil, handler)
+	require.Nil(t, err)
+	require.Equal(t, "alice", identity.(*lib.ClientIdentity).CommonName)
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// authentication methods
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+	AuthMethodHMAC   = "hmac"
+)
+
+// ErrNoCredentials is returned by verifiers when the request carries no credential
+// they understand, so the next verifier is tried
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal defines the authenticated caller
+type Principal struct {
+	Subject string
+	Method  string
+	Scopes  []string
+	Roles   []string
+	Claims  map[string]interface{}
+}
+
+// HasScopes reports whether principal is granted every scope
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !containsString(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAnyRole reports whether principal has one of roles, it is true when roles is empty
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if containsString(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier authenticates requests
+type Verifier interface {
+	Verify(r *http.Request) (*Principal, error)
+}
+
+// VerifierFunc adapts a function to the verifier interface
+type VerifierFunc func(r *http.Request) (*Principal, error)
+
+// Verify runs the verify function
+func (f VerifierFunc) Verify(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// AuthRequirement defines what a route requires from the principal, every scope
+// and any of the roles are required
+type AuthRequirement struct {
+	Scopes []string
+	Roles  []string
+}
+
+// PrincipalFromContext returns the authenticated principal, nil for anonymous requests
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(ContextPrincipalKey).(*Principal)
+	return principal
+}
+
+// RequestPrincipal returns the authenticated principal of the request
+func RequestPrincipal(r *http.Request) *Principal {
+	return PrincipalFromContext(r.Context())
+}
+
+// SetAuthOption set http server authenticates requests by the first verifier which
+// finds credentials, requests without credentials pass as anonymous and are
+// rejected by routes declaring Auth
+func (s *Server) SetAuthOption(verifiers ...Verifier) StartServerOptions {
+	return func() (err error) {
+		if len(verifiers) == 0 {
+			return errors.New("auth option requires a verifier")
+		}
+		s.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, err := authenticate(r, verifiers)
+				if err != nil {
+					s.Logger.For(r.Context()).Info("authentication failed", zap.Error(err))
+					if _, ok := errors.Cause(err).(RequestTooLargeError); ok {
+						SendError(w, err)
+						return
+					}
+					sendAuthError(w, UnauthorizedError{err})
+					return
+				}
+				if principal != nil {
+					r = r.WithContext(context.WithValue(r.Context(), ContextPrincipalKey, principal))
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+		return nil
+	}
+}
+
+func authenticate(r *http.Request, verifiers []Verifier) (*Principal, error) {
+	for _, verifier := range verifiers {
+		principal, err := verifier.Verify(r)
+		if err == ErrNoCredentials {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return principal, nil
+	}
+	return nil, nil
+}
+
+// RequireAuth creates middleware rejecting anonymous requests with 401 and requests
+// whose principal misses the requirement with 403
+func RequireAuth(requirement *AuthRequirement) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := RequestPrincipal(r)
+			if principal == nil {
+				sendAuthError(w, UnauthorizedError{errors.New("authentication is required")})
+				return
+			}
+			if !principal.HasScopes(requirement.Scopes...) {
+				sendAuthError(w, ForbiddenError{errors.New("insufficient scope")})
+				return
+			}
+			if !principal.HasAnyRole(requirement.Roles...) {
+				sendAuthError(w, ForbiddenError{errors.New("insufficient role")})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func sendAuthError(w http.ResponseWriter, err error) {
+	if _, ok := err.(UnauthorizedError); ok {
+		w.Header().Set(HeaderWWWAuthenticate, "Bearer")
+	}
+	SendError(w, err)
+}
+
+// APIKeyVerifier authenticates requests by static api keys
+type APIKeyVerifier struct {
+	// Header carrying the key, defaults to X-Api-Key
+	Header string
+	// Keys maps api key to its principal
+	Keys map[string]Principal
+}
+
+// Verify looks up the api key in constant time
+func (v *APIKeyVerifier) Verify(r *http.Request) (*Principal, error) {
+	header := v.Header
+	if header == "" {
+		header = HeaderAPIKey
+	}
+	key := r.Header.Get(header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	var found *Principal
+	for apiKey, principal := range v.Keys {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			p := principal
+			found = &p
+		}
+	}
+	if found == nil {
+		return nil, errors.New("invalid api key")
+	}
+	found.Method = AuthMethodAPIKey
+	return found, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultSignatureMaxSkew bounds the age of signed requests
+const defaultSignatureMaxSkew = 5 * time.Minute
+
+// defaultSignatureMaxBodySize bounds the body read to verify signatures
+const defaultSignatureMaxBodySize = 10 << 20
+
+// HMACKey defines a request signing key
+type HMACKey struct {
+	Secret    []byte
+	Principal Principal
+}
+
+// HMACVerifier authenticates requests signed by SignRequest
+type HMACVerifier struct {
+	// Keys maps key id to its key
+	Keys map[string]HMACKey
+	// MaxSkew bounds the difference between signing and current time, default 5 minutes
+	MaxSkew time.Duration
+	// MaxBodySize bounds the signed body in bytes, larger bodies are rejected with 413,
+	// default 10MB
+	MaxBodySize int64
+	// Now returns current time, it is replaceable for testing
+	Now func() time.Time
+}
+
+// SignRequest signs method, request uri, timestamp and body of the request with
+// HMAC-SHA256, the body is read and replaced so it can still be sent
+func SignRequest(r *http.Request, keyID string, secret []byte, now time.Time) error {
+	body, err := readRequestBody(r)
+	if err != nil {
+		return errors.Wrap(err, "read body")
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set(HeaderSignatureKey, keyID)
+	r.Header.Set(HeaderSignatureTimestamp, timestamp)
+	r.Header.Set(HeaderSignature, hex.EncodeToString(
+		signatureOf(secret, r.Method, r.URL.RequestURI(), timestamp, body)))
+	return nil
+}
+
+// Verify verifies signature headers of the request
+func (v *HMACVerifier) Verify(r *http.Request) (*Principal, error) {
+	keyID := r.Header.Get(HeaderSignatureKey)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+	key, ok := v.Keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("unknown signature key %q", keyID)
+	}
+	timestamp := r.Header.Get(HeaderSignatureTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid signature timestamp")
+	}
+	now, maxSkew := time.Now(), v.MaxSkew
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if maxSkew <= 0 {
+		maxSkew = defaultSignatureMaxSkew
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > maxSkew || skew < -maxSkew {
+		return nil, errors.New("signature is expired")
+	}
+	signature, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil {
+		return nil, errors.New("invalid signature encoding")
+	}
+	maxBodySize := v.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultSignatureMaxBodySize
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxBodySize)
+	}
+	body, err := readRequestBody(r)
+	if err != nil {
+		if err.Error() == errBodyTooLarge {
+			return nil, RequestTooLargeError{err}
+		}
+		return nil, errors.Wrap(err, "read body")
+	}
+	expected := signatureOf(key.Secret, r.Method, r.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal(expected, signature) {
+		return nil, errors.New("invalid signature")
+	}
+	principal := key.Principal
+	if principal.Subject == "" {
+		principal.Subject = keyID
+	}
+	principal.Method = AuthMethodHMAC
+	return &principal, nil
+}
+
+func signatureOf(secret []byte, method, uri, timestamp string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+	return mac.Sum(nil)
+}
+
+// readRequestBody reads the body and replaces it with an unread copy
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
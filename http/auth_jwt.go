@@ -0,0 +1,334 @@
+package http
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hauxe/gom/environment"
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+const bearerPrefix = "Bearer "
+
+// JWTConfig defines jwt verifier config, keys without kid are used for tokens
+// without kid
+type JWTConfig struct {
+	// Secret is the HS256/384/512 key
+	Secret string `env:"HTTP_AUTH_JWT_SECRET"`
+	// PublicKey is the PEM encoded RS256/384/512 key
+	PublicKey string `env:"HTTP_AUTH_JWT_PUBLIC_KEY"`
+	// JWKSFile is a local json web key set file
+	JWKSFile string `env:"HTTP_AUTH_JWT_JWKS_FILE"`
+	Issuer   string `env:"HTTP_AUTH_JWT_ISSUER"`
+	Audience string `env:"HTTP_AUTH_JWT_AUDIENCE"`
+	// Leeway in seconds tolerates clock skew of exp and nbf
+	Leeway int `env:"HTTP_AUTH_JWT_LEEWAY"`
+}
+
+// JWTVerifier authenticates bearer json web tokens
+type JWTVerifier struct {
+	Config *JWTConfig
+	// Keys maps kid to []byte hmac secret or *rsa.PublicKey
+	Keys map[string]interface{}
+	// Now returns current time, it is replaceable for testing
+	Now func() time.Time
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	K       string `json:"k"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// CreateJWTVerifier creates jwt verifier with keys loaded from env
+func CreateJWTVerifier(options ...environment.CreateENVOptions) (*JWTVerifier, error) {
+	env, err := environment.CreateENV(options...)
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("create jwt verifier", "create env"))
+	}
+	config := JWTConfig{}
+	if err = env.Parse(&config); err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("create jwt verifier", "parse env"))
+	}
+	return NewJWTVerifier(&config)
+}
+
+// NewJWTVerifier creates jwt verifier and loads its keys, secret and public key are
+// exclusive since both are keys of tokens without kid
+func NewJWTVerifier(config *JWTConfig) (*JWTVerifier, error) {
+	if config.Secret != "" && config.PublicKey != "" {
+		return nil, errors.New(lib.StringTags("create jwt verifier", "both secret and public key configured"))
+	}
+	v := &JWTVerifier{Config: config, Keys: make(map[string]interface{}), Now: time.Now}
+	if config.Secret != "" {
+		v.Keys[""] = []byte(config.Secret)
+	}
+	if config.PublicKey != "" {
+		key, err := ParseRSAPublicKeyPEM([]byte(config.PublicKey))
+		if err != nil {
+			return nil, errors.Wrap(err, lib.StringTags("create jwt verifier", "public key"))
+		}
+		v.Keys[""] = key
+	}
+	if config.JWKSFile != "" {
+		keys, err := LoadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, errors.Wrap(err, lib.StringTags("create jwt verifier", "jwks"))
+		}
+		for kid, key := range keys {
+			v.Keys[kid] = key
+		}
+	}
+	if len(v.Keys) == 0 {
+		return nil, errors.New(lib.StringTags("create jwt verifier", "no key configured"))
+	}
+	return v, nil
+}
+
+// ParseRSAPublicKeyPEM parses PKIX or PKCS1 encoded rsa public key
+func ParseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid pem data")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not rsa")
+	}
+	return rsaKey, nil
+}
+
+// LoadJWKS loads RSA and symmetric keys of a json web key set file
+func LoadJWKS(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		switch jwk.KeyType {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+			if err != nil {
+				return nil, errors.Wrap(err, lib.StringTags("jwk", jwk.KeyID))
+			}
+			keys[jwk.KeyID] = secret
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				return nil, errors.Wrap(err, lib.StringTags("jwk", jwk.KeyID))
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				return nil, errors.Wrap(err, lib.StringTags("jwk", jwk.KeyID))
+			}
+			keys[jwk.KeyID] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		default:
+			// unsupported key types are skipped
+		}
+	}
+	return keys, nil
+}
+
+// Verify verifies bearer token of the Authorization header
+func (v *JWTVerifier) Verify(r *http.Request) (*Principal, error) {
+	authorization := r.Header.Get(HeaderAuthorization)
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return nil, ErrNoCredentials
+	}
+	claims, err := v.Parse(strings.TrimPrefix(authorization, bearerPrefix))
+	if err != nil {
+		return nil, err
+	}
+	principal := &Principal{Method: AuthMethodJWT, Claims: claims}
+	principal.Subject, _ = claims["sub"].(string)
+	principal.Scopes = claimStrings(claims["scope"])
+	if len(principal.Scopes) == 0 {
+		principal.Scopes = claimStrings(claims["scp"])
+	}
+	principal.Roles = claimStrings(claims["roles"])
+	return principal, nil
+}
+
+// Parse verifies token signature and registered claims then returns its claims
+func (v *JWTVerifier) Parse(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	header := jwtHeader{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errors.Wrap(err, "token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "token signature")
+	}
+	if err = v.verifySignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errors.Wrap(err, "token claims")
+	}
+	if err = v.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(header jwtHeader, signed string, signature []byte) error {
+	key, ok := v.Keys[header.KeyID]
+	if !ok {
+		return errors.Errorf("unknown key %q", header.KeyID)
+	}
+	if len(header.Algorithm) != 5 {
+		return errors.Errorf("unsupported algorithm %q", header.Algorithm)
+	}
+	hash, ok := jwtHashes[header.Algorithm[2:]]
+	if !ok {
+		return errors.Errorf("unsupported algorithm %q", header.Algorithm)
+	}
+	h := hash.New()
+	// the key type must match the algorithm so a public key is never used as secret
+	switch key := key.(type) {
+	case []byte:
+		if header.Algorithm[:2] != "HS" {
+			return errors.Errorf("algorithm %q does not match key", header.Algorithm)
+		}
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("invalid token signature")
+		}
+	case *rsa.PublicKey:
+		if header.Algorithm[:2] != "RS" {
+			return errors.Errorf("algorithm %q does not match key", header.Algorithm)
+		}
+		h.Write([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return errors.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+func (v *JWTVerifier) verifyClaims(claims map[string]interface{}) error {
+	now, config := time.Now(), v.Config
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if config == nil {
+		config = &JWTConfig{}
+	}
+	leeway := time.Duration(config.Leeway) * time.Second
+	exp, ok, err := claimTime(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if ok && now.After(exp.Add(leeway)) {
+		return errors.New("token is expired")
+	}
+	nbf, ok, err := claimTime(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	if config.Issuer != "" && claims["iss"] != config.Issuer {
+		return errors.New("invalid token issuer")
+	}
+	if config.Audience != "" && !containsString(claimStrings(claims["aud"]), config.Audience) {
+		return errors.New("invalid token audience")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, dst interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(dst)
+}
+
+// claimTime reads numeric date claim name, it is false when the claim is absent and
+// an error when it is not a number
+func claimTime(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	claim, existed := claims[name]
+	if !existed {
+		return time.Time{}, false, nil
+	}
+	number, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, false, errors.Errorf("invalid token %s claim", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false, errors.Errorf("invalid token %s claim", name)
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// claimStrings reads space separated string or string array claims
+func claimStrings(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []interface{}:
+		values := make([]string, 0, len(claim))
+		for _, item := range claim {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	return nil
+}
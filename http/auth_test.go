@@ -0,0 +1,303 @@
+package http
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.Nil(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(header) + "." + encode(claims)
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		hash := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.Nil(t, err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAuthorization, bearerPrefix+token)
+	return r
+}
+
+func TestJWTVerifier(t *testing.T) {
+	t.Parallel()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+	publicKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey),
+	})
+	dir, err := ioutil.TempDir("", "jwks")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	jwks := `{"keys":[{"kty":"RSA","kid":"rsa1","n":"` +
+		base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()) + `","e":"` +
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()) +
+		`"},{"kty":"oct","kid":"hs1","k":"` +
+		base64.RawURLEncoding.EncodeToString([]byte("jwks secret")) + `"},{"kty":"EC","kid":"ec1"}]}`
+	require.Nil(t, ioutil.WriteFile(jwksFile, []byte(jwks), 0600))
+
+	_, err = NewJWTVerifier(&JWTConfig{})
+	require.Error(t, err)
+	_, err = NewJWTVerifier(&JWTConfig{Secret: "secret", PublicKey: string(publicKey)})
+	require.Error(t, err)
+	// struct literal without config and clock
+	literal := &JWTVerifier{Keys: map[string]interface{}{"": []byte("secret")}}
+	principal, err := literal.Verify(bearerRequest(signJWT(t, "HS256", "", []byte("secret"),
+		map[string]interface{}{"sub": "user1", "exp": time.Now().Add(time.Minute).Unix()})))
+	require.Nil(t, err)
+	require.Equal(t, "user1", principal.Subject)
+	verifier, err := NewJWTVerifier(&JWTConfig{
+		PublicKey: string(publicKey),
+		JWKSFile:  jwksFile,
+		Issuer:    "issuer",
+		Audience:  "api",
+		Leeway:    5,
+	})
+	require.Nil(t, err)
+	require.Len(t, verifier.Keys, 3)
+	now := time.Unix(1500000000, 0)
+	verifier.Now = func() time.Time { return now }
+	claims := map[string]interface{}{
+		"sub":   "user1",
+		"iss":   "issuer",
+		"aud":   []string{"api", "web"},
+		"exp":   now.Unix(),
+		"scope": "read write",
+		"roles": []string{"admin"},
+	}
+
+	principal, err = verifier.Verify(bearerRequest(signJWT(t, "RS256", "", rsaKey, claims)))
+	require.Nil(t, err)
+	require.Equal(t, "user1", principal.Subject)
+	require.Equal(t, AuthMethodJWT, principal.Method)
+	require.Equal(t, []string{"read", "write"}, principal.Scopes)
+	require.Equal(t, []string{"admin"}, principal.Roles)
+
+	principal, err = verifier.Verify(bearerRequest(signJWT(t, "RS256", "rsa1", rsaKey, claims)))
+	require.Nil(t, err)
+	principal, err = verifier.Verify(bearerRequest(signJWT(t, "HS256", "hs1", []byte("jwks secret"), claims)))
+	require.Nil(t, err)
+	require.Equal(t, "user1", principal.Subject)
+
+	_, err = verifier.Verify(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, ErrNoCredentials, err)
+
+	testCases := []struct {
+		Name  string
+		Token string
+	}{
+		{"malformed", "abc.def"},
+		{"wrong secret", signJWT(t, "HS256", "hs1", []byte("wrong"), claims)},
+		{"unknown kid", signJWT(t, "HS256", "unknown", []byte("jwks secret"), claims)},
+		// public key must not be accepted as hmac secret
+		{"algorithm confusion", signJWT(t, "HS256", "rsa1", publicKey, claims)},
+		{"unsupported algorithm", signJWT(t, "none", "hs1", []byte("jwks secret"), claims)},
+	}
+	for _, tc := range testCases {
+		_, err = verifier.Verify(bearerRequest(tc.Token))
+		require.Error(t, err, tc.Name)
+	}
+
+	for _, tc := range []struct {
+		Key   string
+		Value interface{}
+	}{
+		{"exp", now.Add(-6 * time.Second).Unix()},
+		{"exp", strconv.FormatInt(now.Add(time.Hour).Unix(), 10)},
+		{"exp", nil},
+		{"nbf", now.Add(6 * time.Second).Unix()},
+		{"nbf", "0"},
+		{"iss", "other"},
+		{"aud", "web"},
+	} {
+		invalid := map[string]interface{}{}
+		for k, v := range claims {
+			invalid[k] = v
+		}
+		invalid[tc.Key] = tc.Value
+		_, err = verifier.Verify(bearerRequest(signJWT(t, "RS256", "rsa1", rsaKey, invalid)))
+		require.Error(t, err, tc.Key, tc.Value)
+	}
+}
+
+func TestAPIKeyVerifier(t *testing.T) {
+	t.Parallel()
+	verifier := &APIKeyVerifier{Keys: map[string]Principal{
+		"key1": {Subject: "service1", Scopes: []string{"read"}},
+	}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := verifier.Verify(r)
+	require.Equal(t, ErrNoCredentials, err)
+
+	r.Header.Set(HeaderAPIKey, "key1")
+	principal, err := verifier.Verify(r)
+	require.Nil(t, err)
+	require.Equal(t, "service1", principal.Subject)
+	require.Equal(t, AuthMethodAPIKey, principal.Method)
+	require.True(t, principal.HasScopes("read"))
+	require.False(t, principal.HasScopes("read", "write"))
+	require.True(t, principal.HasAnyRole())
+	require.False(t, principal.HasAnyRole("admin"))
+
+	r.Header.Set(HeaderAPIKey, "key2")
+	_, err = verifier.Verify(r)
+	require.Error(t, err)
+}
+
+func TestHMACVerifier(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1500000000, 0)
+	verifier := &HMACVerifier{
+		Keys: map[string]HMACKey{
+			"client1": {Secret: []byte("secret"), Principal: Principal{Roles: []string{"partner"}}},
+		},
+		Now: func() time.Time { return now },
+	}
+	newRequest := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/orders?id=1", strings.NewReader(`{"a":1}`))
+	}
+	r := newRequest()
+	_, err := verifier.Verify(r)
+	require.Equal(t, ErrNoCredentials, err)
+
+	require.Nil(t, SignRequest(r, "client1", []byte("secret"), now.Add(-time.Minute)))
+	principal, err := verifier.Verify(r)
+	require.Nil(t, err)
+	require.Equal(t, "client1", principal.Subject)
+	require.Equal(t, AuthMethodHMAC, principal.Method)
+	require.True(t, principal.HasAnyRole("partner"))
+	// body is still readable by the handler
+	body, err := ioutil.ReadAll(r.Body)
+	require.Nil(t, err)
+	require.Equal(t, `{"a":1}`, string(body))
+
+	r = newRequest()
+	require.Nil(t, SignRequest(r, "client1", []byte("secret"), now.Add(-time.Hour)))
+	_, err = verifier.Verify(r)
+	require.Error(t, err)
+
+	r = newRequest()
+	require.Nil(t, SignRequest(r, "client1", []byte("wrong"), now))
+	_, err = verifier.Verify(r)
+	require.Error(t, err)
+
+	r = newRequest()
+	require.Nil(t, SignRequest(r, "client1", []byte("secret"), now))
+	r.Body = ioutil.NopCloser(strings.NewReader(`{"a":2}`))
+	_, err = verifier.Verify(r)
+	require.Error(t, err)
+
+	verifier.MaxBodySize = 4
+	r = newRequest()
+	require.Nil(t, SignRequest(r, "client1", []byte("secret"), now))
+	_, err = verifier.Verify(r)
+	require.IsType(t, RequestTooLargeError{}, err)
+	w := httptest.NewRecorder()
+	SendError(w, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestServerAuth(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		principal := RequestPrincipal(r)
+		subject := "anonymous"
+		if principal != nil {
+			subject = principal.Subject
+		}
+		SendResponse(w, http.StatusOK, ErrorCodeSuccess, subject, nil)
+	}
+	err = server.Start(server.SetHostPortOption("localhost", 18009),
+		server.SetAuthOption(&APIKeyVerifier{Keys: map[string]Principal{
+			"reader": {Subject: "reader", Scopes: []string{"orders:read"}},
+			"writer": {Subject: "writer", Scopes: []string{"orders:read", "orders:write"}},
+		}}),
+		server.SetHandlerOption(
+			ServerRoute{Name: "public", Method: http.MethodGet, Path: "/public", Handler: handler},
+			ServerRoute{Name: "read", Method: http.MethodGet, Path: "/orders", Handler: handler,
+				Auth: &AuthRequirement{Scopes: []string{"orders:read"}}},
+			ServerRoute{Name: "write", Method: http.MethodPost, Path: "/orders", Handler: handler,
+				Auth: &AuthRequirement{Scopes: []string{"orders:write"}}},
+		))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+	send := func(method, path, apiKey string) (*http.Response, ServerResponse) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.Nil(t, err)
+		if apiKey != "" {
+			req.Header.Set(HeaderAPIKey, apiKey)
+		}
+		resp, err := hc.Do(req)
+		require.Nil(t, err)
+		dest := ServerResponse{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+		return resp, dest
+	}
+
+	resp, dest := send(http.MethodGet, "/public", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "anonymous", dest.ErrorMessage)
+
+	resp, dest = send(http.MethodGet, "/public", "invalid")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, ErrorCodeUnauthorized, dest.ErrorCode)
+	require.Equal(t, "Bearer", resp.Header.Get(HeaderWWWAuthenticate))
+
+	resp, dest = send(http.MethodGet, "/orders", "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, ErrorCodeUnauthorized, dest.ErrorCode)
+
+	resp, dest = send(http.MethodGet, "/orders", "reader")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "reader", dest.ErrorMessage)
+
+	resp, dest = send(http.MethodPost, "/orders", "reader")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, ErrorCodeForbidden, dest.ErrorCode)
+
+	resp, dest = send(http.MethodPost, "/orders", "writer")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "writer", dest.ErrorMessage)
+}
@@ -8,19 +8,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/hauxe/gom/environment"
 
-	"github.com/opentracing/opentracing-go"
-
 	lib "github.com/hauxe/gom/library"
 	sdklog "github.com/hauxe/gom/log"
 	"github.com/hauxe/gom/trace"
-	ext "github.com/opentracing/opentracing-go/ext"
 	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
 )
 
 // StartClientOptions type indicates start client options
@@ -30,40 +29,71 @@ type StartClientOptions func() error
 type SendClientOptions func(*RequestOption) error
 
 const (
-	timeout         = 32
-	tlsVerification = false
+	// default http client config
+	timeout             = 32
+	tlsVerification     = false
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 10
+	idleConnTimeout     = 90
+	dialTimeout         = 30
+	tlsHandshakeTimeout = 10
+	enableHTTP2         = true
 )
 
-// ClientConfig contains default config for http client
+// ClientConfig contains default config for http client, timeouts are in seconds
 type ClientConfig struct {
-	Timeout         int  `env:"HTTP_CLIENT_TIMEOUT"`
-	TLSVerification bool `env:"HTTP_CLIENT_TLS_VERIFICATION"`
+	Timeout             int  `env:"HTTP_CLIENT_TIMEOUT"`
+	TLSVerification     bool `env:"HTTP_CLIENT_TLS_VERIFICATION"`
+	MaxIdleConns        int  `env:"HTTP_CLIENT_MAX_IDLE_CONNS"`
+	MaxIdleConnsPerHost int  `env:"HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST"`
+	IdleConnTimeout     int  `env:"HTTP_CLIENT_IDLE_CONN_TIMEOUT"`
+	DialTimeout         int  `env:"HTTP_CLIENT_DIAL_TIMEOUT"`
+	TLSHandshakeTimeout int  `env:"HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT"`
+	HTTP2               bool `env:"HTTP_CLIENT_HTTP2"`
 }
 
 // RequestOption contains optional header, query, body, timeout of the request
 type RequestOption struct {
-	Header    map[string]interface{}
-	Query     map[string]interface{}
-	Body      io.Reader
-	Timeout   time.Duration
-	Transport *http.Transport
-	headerMux sync.Mutex
+	Header      map[string]interface{}
+	Query       map[string]interface{}
+	Body        io.Reader
+	Timeout     time.Duration
+	Transport   *http.Transport
+	Retry       *RetryOption
+	Circuit     CircuitBreaker
+	CircuitName string
+	headerMux   sync.Mutex
 }
 
-// Client defines GRPC client properties
+// Client defines HTTP client properties
 type Client struct {
 	Config      *ClientConfig
 	TraceClient *trace.Client
 	Logger      sdklog.Factory
+	// Transport is shared by requests so connections are reused, it is created by
+	// Connect or the first request
+	Transport *http.Transport
+	// wrap Transport, see Use
+	middlewares  []ClientMiddleware
+	transportMux sync.Mutex
 }
 
-// CreateClient creates GRPC client
+// CreateClient creates HTTP client
 func CreateClient(options ...environment.CreateENVOptions) (client *Client, err error) {
 	env, err := environment.CreateENV(options...)
 	if err != nil {
 		return nil, errors.Wrap(err, lib.StringTags("create server", "create env"))
 	}
-	config := ClientConfig{timeout, tlsVerification}
+	config := ClientConfig{
+		Timeout:             timeout,
+		TLSVerification:     tlsVerification,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+		DialTimeout:         dialTimeout,
+		TLSHandshakeTimeout: tlsHandshakeTimeout,
+		HTTP2:               enableHTTP2,
+	}
 	if err = env.Parse(&config); err != nil {
 		return nil, errors.Wrap(err, lib.StringTags("create client", "parse env"))
 	}
@@ -84,15 +114,67 @@ func (c *Client) Connect(options ...StartClientOptions) (err error) {
 			return errors.Wrap(err, lib.StringTags("connect client", "option error"))
 		}
 	}
+	if _, err = c.sharedTransport(); err != nil {
+		return errors.Wrap(err, lib.StringTags("connect client", "create transport"))
+	}
 	return err
 }
 
 // Disconnect disconnect client
 func (c *Client) Disconnect() error {
+	if c.Transport != nil {
+		c.Transport.CloseIdleConnections()
+	}
 	return nil
 }
 
-// SetTracerOption set tracer
+// sharedTransport returns the transport of the client, it is created on first use
+func (c *Client) sharedTransport() (*http.Transport, error) {
+	c.transportMux.Lock()
+	defer c.transportMux.Unlock()
+	if c.Transport == nil {
+		transport, err := c.newTransport()
+		if err != nil {
+			return nil, err
+		}
+		c.Transport = transport
+	}
+	return c.Transport, nil
+}
+
+// newTransport creates transport of the client config
+func (c *Client) newTransport() (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(c.Config.DialTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: !c.Config.TLSVerification},
+		TLSHandshakeTimeout: time.Duration(c.Config.TLSHandshakeTimeout) * time.Second,
+		MaxIdleConns:        c.Config.MaxIdleConns,
+		MaxIdleConnsPerHost: c.Config.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(c.Config.IdleConnTimeout) * time.Second,
+	}
+	if c.Config.HTTP2 {
+		// custom tls config disables HTTP/2 unless it is configured explicitly
+		if err := http2.ConfigureTransport(transport); err != nil {
+			return nil, err
+		}
+	}
+	return transport, nil
+}
+
+// SetTransportOption set client uses transport instead of creating one from config
+func (c *Client) SetTransportOption(transport *http.Transport) StartClientOptions {
+	return func() (err error) {
+		c.Transport = transport
+		return nil
+	}
+}
+
+// SetTracerOption set tracer, requests are traced by TracingClientMiddleware
 func (c *Client) SetTracerOption(tracer *trace.Client) StartClientOptions {
 	return func() (err error) {
 		c.TraceClient = tracer
@@ -103,44 +185,19 @@ func (c *Client) SetTracerOption(tracer *trace.Client) StartClientOptions {
 // Send sends general request to a URL and returns HTTP response
 func (c *Client) Send(ctx context.Context, method string, url string,
 	options ...SendClientOptions) (res *http.Response, err error) {
-	var request *http.Request
-	if c.TraceClient != nil {
-		ctx, err = c.TraceClient.StartTracing(ctx,
-			trace.Tag(string(ext.HTTPMethod), method),
-			trace.Tag(string(ext.HTTPUrl), url))
-		if err != nil {
-			return nil, errors.Wrap(err, lib.StringTags("client send", "trace error"))
-		}
-		// Inject the Span context into the outgoing HTTP Request.
-		if err := c.TraceClient.Tracer.Inject(
-			opentracing.SpanFromContext(ctx).Context(),
-			opentracing.TextMap,
-			opentracing.HTTPHeadersCarrier(request.Header),
-		); err != nil {
-			return nil, errors.Wrap(err, lib.StringTags("client send", "error encountered while trying to inject span"))
-		}
-		defer func(res *http.Response) {
-			if res != nil {
-				tags := []opentracing.StartSpanOption{trace.Tag(string(ext.HTTPStatusCode), res.StatusCode)}
-				if res.StatusCode != http.StatusOK {
-					body, err := ReadBodyString(res)
-					if err != nil {
-						c.Logger.For(ctx).Fatal(err.Error())
-						return
-					}
-					tags = append(tags, trace.Tag("http.body", body))
-				}
-				c.TraceClient.StopTracing(ctx, err, tags...)
-			}
-		}(res)
-	}
 	requestOption := &RequestOption{}
 	for _, op := range options {
 		if err = op(requestOption); err != nil {
 			return nil, errors.Wrap(err, lib.StringTags("client send", "option error"))
 		}
 	}
-	request, err = http.NewRequest(method, url, requestOption.Body)
+	transport := requestOption.Transport
+	if transport == nil {
+		if transport, err = c.sharedTransport(); err != nil {
+			return nil, errors.Wrap(err, lib.StringTags("client send", "create transport"))
+		}
+	}
+	request, err := http.NewRequest(method, url, requestOption.Body)
 
 	if err != nil {
 		return nil, err
@@ -159,19 +216,18 @@ func (c *Client) Send(ctx context.Context, method string, url string,
 			request.Header.Set(key, lib.ToString(val))
 		}
 	}
+	// forward request id unless the caller sets its own
+	if requestID := lib.RequestIDFromContext(ctx); requestID != "" &&
+		request.Header.Get(HeaderRequestID) == "" {
+		request.Header.Set(HeaderRequestID, requestID)
+	}
 	timeout := requestOption.Timeout
 	if timeout <= 0 {
 		timeout = time.Duration(c.Config.Timeout) * time.Second
 	}
-	transport := requestOption.Transport
-	if transport == nil {
-		transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: !c.Config.TLSVerification},
-		}
-	}
-	request.WithContext(ctx)
-	client := &http.Client{Timeout: timeout, Transport: transport}
-	res, err = client.Do(request)
+	request = request.WithContext(ctx)
+	client := &http.Client{Timeout: timeout, Transport: c.roundTripper(transport)}
+	res, err = c.do(ctx, client, request, requestOption)
 	return res, err
 }
 
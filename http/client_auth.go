@@ -0,0 +1,194 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// defaultTokenExpiryDelta refreshes tokens before they expire
+const defaultTokenExpiryDelta = 10 * time.Second
+
+// Token defines an access token sent in the Authorization header
+type Token struct {
+	AccessToken string
+	// TokenType is Bearer by default
+	TokenType string
+	// Expiry is zero for tokens which do not expire
+	Expiry time.Time
+}
+
+// TokenSource returns the token of requests
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// tokenInvalidator drops the cached token rejected by the server
+type tokenInvalidator interface {
+	invalidate(token *Token)
+}
+
+type staticToken struct {
+	token *Token
+}
+
+// StaticToken returns token source of a bearer token which does not expire
+func StaticToken(accessToken string) TokenSource {
+	return &staticToken{token: &Token{AccessToken: accessToken}}
+}
+
+func (s *staticToken) Token(context.Context) (*Token, error) {
+	return s.token, nil
+}
+
+// ClientCredentialsConfig defines OAuth2 client credentials grant
+type ClientCredentialsConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// ExpiryDelta refreshes tokens before they expire, 10 seconds by default
+	ExpiryDelta time.Duration
+	// HTTPClient requests tokens, http.DefaultClient by default
+	HTTPClient *http.Client
+}
+
+type clientCredentials struct {
+	config *ClientCredentialsConfig
+	token  *Token
+	mux    sync.Mutex
+}
+
+// ClientCredentialsTokenSource returns token source requesting tokens by OAuth2
+// client credentials grant, tokens are cached until they expire
+func ClientCredentialsTokenSource(config *ClientCredentialsConfig) TokenSource {
+	return &clientCredentials{config: config}
+}
+
+func (cc *clientCredentials) Token(ctx context.Context) (*Token, error) {
+	cc.mux.Lock()
+	defer cc.mux.Unlock()
+	delta := cc.config.ExpiryDelta
+	if delta == 0 {
+		delta = defaultTokenExpiryDelta
+	}
+	if cc.token != nil && (cc.token.Expiry.IsZero() || time.Now().Add(delta).Before(cc.token.Expiry)) {
+		return cc.token, nil
+	}
+	token, err := cc.request(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cc.token = token
+	return token, nil
+}
+
+func (cc *clientCredentials) invalidate(token *Token) {
+	cc.mux.Lock()
+	defer cc.mux.Unlock()
+	if cc.token == token {
+		cc.token = nil
+	}
+}
+
+// request requests a token, client credentials are sent by basic authentication
+func (cc *clientCredentials) request(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cc.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(cc.config.Scopes, " "))
+	}
+	request, err := http.NewRequest(http.MethodPost, cc.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("request token", "create request"))
+	}
+	request.Header.Set(HeaderContentType, ContentTypeForm)
+	request.Header.Set(HeaderAccept, ContentTypeJSON)
+	request.SetBasicAuth(url.QueryEscape(cc.config.ClientID), url.QueryEscape(cc.config.ClientSecret))
+	client := cc.config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("request token", "send"))
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("request token", "read body"))
+	}
+	var data struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.Unmarshal(body, &data); err != nil && res.StatusCode == http.StatusOK {
+		return nil, errors.Wrap(err, lib.StringTags("request token", "decode body"))
+	}
+	if res.StatusCode != http.StatusOK || data.AccessToken == "" {
+		return nil, errors.New(lib.StringTags("request token",
+			fmt.Sprintf("status %d", res.StatusCode), data.Error, data.ErrorDescription))
+	}
+	token := &Token{AccessToken: data.AccessToken, TokenType: data.TokenType}
+	if data.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// TokenClientMiddleware sets the Authorization header to the token of source. A
+// rejected token is dropped and the request is sent once more with a new one when its
+// body can be replayed
+func TokenClientMiddleware(source TokenSource) ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			res, token, err := roundTripWithToken(next, r, source)
+			if err != nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+			invalidator, ok := source.(tokenInvalidator)
+			if !ok || (r.Body != nil && r.Body != http.NoBody && r.GetBody == nil) {
+				return res, err
+			}
+			invalidator.invalidate(token)
+			if r.GetBody != nil {
+				body, err := r.GetBody()
+				if err != nil {
+					return res, nil
+				}
+				r = r.WithContext(r.Context())
+				r.Body = body
+			}
+			res.Body.Close()
+			res, _, err = roundTripWithToken(next, r, source)
+			return res, err
+		})
+	}
+}
+
+func roundTripWithToken(next http.RoundTripper, r *http.Request,
+	source TokenSource) (*http.Response, *Token, error) {
+	token, err := source.Token(r.Context())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, lib.StringTags("token middleware", "get token"))
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	r = cloneRequest(r)
+	r.Header.Set(HeaderAuthorization, tokenType+" "+token.AccessToken)
+	res, err := next.RoundTrip(r)
+	return res, token, err
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// ResponseError is a response of a GoM server without success error code, it is a
+// StatusError so services forwarding it reply with its status and error code
+type ResponseError struct {
+	StatusCode int
+	Code       ErrorCode
+	Message    string
+	// Data is the error data of the response
+	Data json.RawMessage
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("response status %d error code %d: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Status returns response status
+func (e *ResponseError) Status() int {
+	return e.StatusCode
+}
+
+// ErrorCode returns response error code
+func (e *ResponseError) ErrorCode() ErrorCode {
+	return e.Code
+}
+
+// Details returns error data of the response
+func (e *ResponseError) Details() interface{} {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return e.Data
+}
+
+// DecodeData decodes error data of the response into dest
+func (e *ResponseError) DecodeData(dest interface{}) error {
+	if len(e.Data) == 0 {
+		return errors.New(lib.StringTags("decode error data", "response has no error data"))
+	}
+	return json.Unmarshal(e.Data, dest)
+}
+
+// clientResponse is ServerResponse with undecoded data
+type clientResponse struct {
+	ErrorCode    ErrorCode `json:"error_code"`
+	ErrorMessage string    `json:"error_message"`
+	Data         struct {
+		Success json.RawMessage `json:"success"`
+		Error   json.RawMessage `json:"error"`
+	} `json:"data"`
+}
+
+// GetJSON sends GET request and decodes success data of the response into dest
+func (c *Client) GetJSON(ctx context.Context, url string, dest interface{},
+	options ...SendClientOptions) error {
+	return c.DoJSON(ctx, http.MethodGet, url, nil, dest, options...)
+}
+
+// PostJSON sends POST request with JSON body and decodes success data of the response
+// into dest
+func (c *Client) PostJSON(ctx context.Context, url string, body, dest interface{},
+	options ...SendClientOptions) error {
+	return c.DoJSON(ctx, http.MethodPost, url, body, dest, options...)
+}
+
+// PutJSON sends PUT request with JSON body and decodes success data of the response
+// into dest
+func (c *Client) PutJSON(ctx context.Context, url string, body, dest interface{},
+	options ...SendClientOptions) error {
+	return c.DoJSON(ctx, http.MethodPut, url, body, dest, options...)
+}
+
+// DeleteJSON sends DELETE request and decodes success data of the response into dest
+func (c *Client) DeleteJSON(ctx context.Context, url string, dest interface{},
+	options ...SendClientOptions) error {
+	return c.DoJSON(ctx, http.MethodDelete, url, nil, dest, options...)
+}
+
+// DoJSON sends request with body encoded as JSON, nil sends no body, and decodes
+// success data of the ServerResponse into dest, nil discards it. Success responses
+// without body, e.g. 204, have no data. Responses with error status or error code are
+// returned as *ResponseError, success statuses with error code are reported as 502 so
+// forwarding them does not reply success
+func (c *Client) DoJSON(ctx context.Context, method, url string, body, dest interface{},
+	options ...SendClientOptions) error {
+	options = append([]SendClientOptions{c.SetRequestOptionHeader(map[string]interface{}{
+		HeaderAccept: ContentTypeJSON,
+	})}, options...)
+	if body != nil {
+		options = append(options, c.SetRequestOptionJSON(body))
+	}
+	resp, err := c.Send(ctx, method, url, options...)
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("do json", method, url))
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("do json", "read body"))
+	}
+	success := resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+	if success && len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	res := clientResponse{}
+	if err = json.Unmarshal(data, &res); err != nil {
+		if !success {
+			// error responses of proxies are not envelopes
+			return &ResponseError{StatusCode: resp.StatusCode, Code: ErrorCodeFailed,
+				Message: http.StatusText(resp.StatusCode)}
+		}
+		return errors.Wrap(err, lib.StringTags("do json", "decode response"))
+	}
+	if !success {
+		return &ResponseError{StatusCode: resp.StatusCode, Code: res.ErrorCode,
+			Message: res.ErrorMessage, Data: res.Data.Error}
+	}
+	if res.ErrorCode != ErrorCodeSuccess {
+		return &ResponseError{StatusCode: http.StatusBadGateway, Code: res.ErrorCode,
+			Message: res.ErrorMessage, Data: res.Data.Error}
+	}
+	if dest == nil || len(res.Data.Success) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(res.Data.Success, dest); err != nil {
+		return errors.Wrap(err, lib.StringTags("do json", "decode success data"))
+	}
+	return nil
+}
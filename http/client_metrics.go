@@ -0,0 +1,111 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errorStatus labels requests which got no response
+const errorStatus = "error"
+
+type clientMetricLabels struct {
+	host   string
+	method string
+	status string
+}
+
+func (l clientMetricLabels) String() string {
+	return fmt.Sprintf(`host="%s",method="%s",status="%s"`,
+		escapeLabelValue(l.host), escapeLabelValue(l.method), escapeLabelValue(l.status))
+}
+
+// ClientMetrics collects http client request metrics and exposes them in prometheus
+// text format
+type ClientMetrics struct {
+	DurationBuckets []float64
+	inFlight        int64
+	requests        map[clientMetricLabels]uint64
+	durations       map[clientMetricLabels]*histogram
+	mux             sync.Mutex
+}
+
+// NewClientMetrics creates client metrics collector with default buckets
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{
+		DurationBuckets: DefaultDurationBuckets,
+		requests:        make(map[clientMetricLabels]uint64),
+		durations:       make(map[clientMetricLabels]*histogram),
+	}
+}
+
+// Middleware records request count, latency until response headers and in-flight
+// requests by host
+func (m *ClientMetrics) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+		start := time.Now()
+		res, err := next.RoundTrip(r)
+		status := errorStatus
+		if err == nil {
+			status = strconv.Itoa(res.StatusCode)
+		}
+		m.observe(clientMetricLabels{
+			host:   r.URL.Host,
+			method: metricMethod(r.Method),
+			status: status,
+		}, time.Since(start))
+		return res, err
+	})
+}
+
+func (m *ClientMetrics) observe(labels clientMetricLabels, duration time.Duration) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.requests[labels]++
+	if _, existed := m.durations[labels]; !existed {
+		m.durations[labels] = newHistogram(m.DurationBuckets)
+	}
+	m.durations[labels].observe(duration.Seconds())
+}
+
+// ServeHTTP writes metrics in prometheus text exposition format
+func (m *ClientMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(HeaderContentType, metricsContentType)
+	w.Write(m.Expose())
+}
+
+// Expose returns metrics in prometheus text exposition format
+func (m *ClientMetrics) Expose() []byte {
+	var buf bytes.Buffer
+	m.mux.Lock()
+	labels := make([]clientMetricLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].String() < labels[j].String()
+	})
+	writeMetricHeader(&buf, "http_client_requests_total", "counter",
+		"Total number of HTTP client requests.")
+	for _, l := range labels {
+		fmt.Fprintf(&buf, "http_client_requests_total{%s} %d\n", l, m.requests[l])
+	}
+	writeMetricHeader(&buf, "http_client_request_duration_seconds", "histogram",
+		"HTTP client request latency in seconds.")
+	for _, l := range labels {
+		writeHistogram(&buf, "http_client_request_duration_seconds", l, m.durations[l])
+	}
+	m.mux.Unlock()
+
+	writeMetricHeader(&buf, "http_client_requests_in_flight", "gauge",
+		"Number of HTTP client requests being sent.")
+	fmt.Fprintf(&buf, "http_client_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight))
+	return buf.Bytes()
+}
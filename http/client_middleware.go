@@ -0,0 +1,156 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	sdklog "github.com/hauxe/gom/log"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r)
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// ClientMiddleware wraps the round tripper of client requests with extra behavior,
+// it runs once per attempt of retried requests
+type ClientMiddleware func(http.RoundTripper) http.RoundTripper
+
+// ChainClient composes client middlewares into one, the first middleware is the
+// outermost so it runs first on request and last on response
+func ChainClient(middlewares ...ClientMiddleware) ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			if middlewares[i] != nil {
+				next = middlewares[i](next)
+			}
+		}
+		return next
+	}
+}
+
+// Use appends middlewares to the client chain which wraps every request, they run in
+// the order they are added
+func (c *Client) Use(middlewares ...ClientMiddleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// SetMiddlewareOption set http client middlewares
+func (c *Client) SetMiddlewareOption(middlewares ...ClientMiddleware) StartClientOptions {
+	return func() (err error) {
+		c.Use(middlewares...)
+		return nil
+	}
+}
+
+// roundTripper wraps transport with the client middlewares, the tracer of the client
+// is the outermost one
+func (c *Client) roundTripper(transport http.RoundTripper) http.RoundTripper {
+	middlewares := c.middlewares
+	if c.TraceClient != nil && c.TraceClient.Tracer != nil {
+		middlewares = append([]ClientMiddleware{TracingClientMiddleware(c.TraceClient.Tracer)},
+			middlewares...)
+	}
+	return ChainClient(middlewares...)(transport)
+}
+
+// cloneRequest returns a copy of r with its own header, round trippers must not
+// modify the request they are given
+func cloneRequest(r *http.Request) *http.Request {
+	clone := r.WithContext(r.Context())
+	clone.Header = make(http.Header, len(r.Header))
+	for key, values := range r.Header {
+		clone.Header[key] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// TracingClientMiddleware starts a client span per request, child of the span of the
+// request context, and injects it into the request headers
+func TracingClientMiddleware(tracer opentracing.Tracer) ClientMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			options := []opentracing.StartSpanOption{ext.SpanKindRPCClient}
+			if parent := opentracing.SpanFromContext(r.Context()); parent != nil {
+				options = append(options, opentracing.ChildOf(parent.Context()))
+			}
+			span := tracer.StartSpan("http.client "+r.Method, options...)
+			defer span.Finish()
+			ext.HTTPMethod.Set(span, r.Method)
+			ext.HTTPUrl.Set(span, r.URL.String())
+			r = cloneRequest(r)
+			r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+			err := tracer.Inject(span.Context(), opentracing.HTTPHeaders,
+				opentracing.HTTPHeadersCarrier(r.Header))
+			if err != nil {
+				span.LogKV("event", "inject", "error", err.Error())
+			}
+			res, err := next.RoundTrip(r)
+			if err != nil {
+				ext.Error.Set(span, true)
+				span.LogKV("event", "error", "message", err.Error())
+				return res, err
+			}
+			ext.HTTPStatusCode.Set(span, uint16(res.StatusCode))
+			if res.StatusCode >= http.StatusInternalServerError {
+				ext.Error.Set(span, true)
+			}
+			return res, nil
+		})
+	}
+}
+
+// LoggingClientMiddleware logs every request and response, headers and query keys are
+// redacted as configured. Nil config uses DefaultAccessLogConfig, excluded paths and
+// sample rate apply as for server access logs
+func LoggingClientMiddleware(logger sdklog.Factory, config *AccessLogConfig) ClientMiddleware {
+	if config == nil {
+		config = DefaultAccessLogConfig()
+	}
+	al := &AccessLogMiddleware{Config: config, Logger: logger}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if al.excluded(r.URL.Path) {
+				return next.RoundTrip(r)
+			}
+			start := time.Now()
+			res, err := next.RoundTrip(r)
+			failed := err != nil || res.StatusCode >= http.StatusInternalServerError
+			if !failed && !al.sampled() {
+				return res, err
+			}
+			fields := []zapcore.Field{
+				zap.String("method", r.Method),
+				zap.String("host", r.URL.Host),
+				zap.String("path", r.URL.Path),
+				zap.String("query", config.redactQuery(r.URL.RawQuery)),
+				zap.Duration("duration", time.Since(start)),
+			}
+			if config.LogHeaders {
+				fields = append(fields, zap.Any("headers", config.redactHeaders(r.Header)))
+			}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			} else {
+				fields = append(fields, zap.Int("status", res.StatusCode),
+					zap.Int64("bytes", res.ContentLength))
+				if config.LogHeaders {
+					fields = append(fields, zap.Any("response_headers", config.redactHeaders(res.Header)))
+				}
+			}
+			if failed {
+				logger.For(r.Context()).Error("http client", fields...)
+			} else {
+				logger.For(r.Context()).Info("http client", fields...)
+			}
+			return res, err
+		})
+	}
+}
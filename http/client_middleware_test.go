@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainClient(t *testing.T) {
+	t.Parallel()
+	order := []string{}
+	tag := func(name string) ClientMiddleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(r)
+			})
+		}
+	}
+	rt := ChainClient(tag("first"), nil, tag("second"))(RoundTripperFunc(
+		func(r *http.Request) (*http.Response, error) {
+			order = append(order, "transport")
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}))
+	r, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.Nil(t, err)
+	_, err = rt.RoundTrip(r)
+	require.Nil(t, err)
+	require.Equal(t, []string{"first", "second", "transport"}, order)
+}
+
+func TestClientMiddlewares(t *testing.T) {
+	t.Parallel()
+	var issued int32
+	server := CreateSampleServer(
+		ServerRoute{
+			Path: "/token",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				id, secret, ok := r.BasicAuth()
+				if !ok || id != "service" || secret != "s3cret" ||
+					r.FormValue("grant_type") != "client_credentials" {
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write([]byte(`{"error":"invalid_client"}`))
+					return
+				}
+				n := atomic.AddInt32(&issued, 1)
+				fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+			},
+		},
+		ServerRoute{
+			Path: "/resource",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				// the first token is revoked
+				if r.Header.Get(HeaderAuthorization) != "Bearer token-2" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(r.Header.Get("Mockpfx-Ids-Traceid")))
+			},
+		})
+	tracer := mocktracer.New()
+	logger, buf := createBufferLogger()
+	logConfig := DefaultAccessLogConfig()
+	logConfig.LogHeaders = true
+	metrics := NewClientMetrics()
+	client, err := CreateClient()
+	require.Nil(t, err)
+	require.Nil(t, client.Connect(client.SetMiddlewareOption(
+		TracingClientMiddleware(tracer),
+		TokenClientMiddleware(ClientCredentialsTokenSource(&ClientCredentialsConfig{
+			TokenURL:     server.URL + "/token",
+			ClientID:     "service",
+			ClientSecret: "s3cret",
+			Scopes:       []string{"read"},
+		})),
+		LoggingClientMiddleware(logger, logConfig),
+		metrics.Middleware,
+	)))
+	defer client.Disconnect()
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	resp, err := client.Send(ctx, http.MethodGet, server.URL+"/resource?access_token=secret")
+	require.Nil(t, err)
+	body, err := ReadBodyString(resp)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&issued))
+	// token is cached
+	resp, err = client.Send(ctx, http.MethodGet, server.URL+"/resource")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, int32(2), atomic.LoadInt32(&issued))
+
+	// client spans are children of the request span and injected
+	parent.Finish()
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 3)
+	require.Equal(t, "http.client GET", spans[0].OperationName)
+	require.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+	require.Equal(t, fmt.Sprint(spans[0].SpanContext.TraceID), body)
+	require.Equal(t, uint16(http.StatusOK), spans[0].Tag("http.status_code"))
+
+	// rejected token is logged before the request is sent with a new one
+	entries := decodeLogEntries(t, buf)
+	require.Len(t, entries, 3)
+	require.Equal(t, float64(http.StatusUnauthorized), entries[0]["status"])
+	require.Equal(t, "http client", entries[1]["msg"])
+	require.Equal(t, "/resource", entries[1]["path"])
+	require.Equal(t, "access_token=%5BREDACTED%5D", entries[1]["query"])
+	require.Equal(t, float64(http.StatusOK), entries[1]["status"])
+	headers, ok := entries[1]["headers"].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, redactedValue, headers[HeaderAuthorization])
+
+	exposed := string(metrics.Expose())
+	host := strings.TrimPrefix(server.URL, "http://")
+	require.Contains(t, exposed, fmt.Sprintf(
+		`http_client_requests_total{host="%s",method="GET",status="200"} 2`, host))
+	require.Contains(t, exposed, fmt.Sprintf(
+		`http_client_requests_total{host="%s",method="GET",status="401"} 1`, host))
+	require.Contains(t, exposed, "http_client_requests_in_flight 0")
+
+	source := ClientCredentialsTokenSource(&ClientCredentialsConfig{
+		TokenURL: server.URL + "/token",
+		ClientID: "unknown",
+	})
+	_, err = source.Token(ctx)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "invalid_client")
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	lib "github.com/hauxe/gom/library"
+	"github.com/hauxe/gom/retry"
+	opentracing "github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+)
+
+// DefaultRetryStatusCodes are response status codes retried by default
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// defaultMaxRetryAfter bounds the Retry-After wait of retried requests
+const defaultMaxRetryAfter = 30 * time.Second
+
+// errServerStatus counts server error responses as failures of the circuit
+var errServerStatus = errors.New("server error status")
+
+// CircuitBreaker executes functions in named circuits, e.g. circuitbreaker.Client
+// with auto start for circuits named by hosts
+type CircuitBreaker interface {
+	Execute(ctx context.Context, name string, runFunc func(context.Context) error,
+		fallbackFunc func(context.Context, error) error) error
+}
+
+// RetryOption defines retries of idempotent requests on network errors and status codes
+type RetryOption struct {
+	Retry       retry.Func
+	MaxRetries  uint64
+	StatusCodes []int
+	// MaxRetryAfter bounds the Retry-After wait, responses asking for longer are
+	// returned without retry. Default 30 seconds
+	MaxRetryAfter time.Duration
+}
+
+// SetRequestOptionRetry set idempotent request is retried at most maxRetries times on
+// network errors or statusCodes, DefaultRetryStatusCodes by default. Nil retryFunc
+// uses exponential backoff, Retry-After of the response up to 30 seconds overrides it
+func (c *Client) SetRequestOptionRetry(maxRetries uint64, retryFunc retry.Func,
+	statusCodes ...int) SendClientOptions {
+	return func(ro *RequestOption) error {
+		if retryFunc == nil {
+			retryFunc = retry.UseExponentialRetry()
+		}
+		if len(statusCodes) == 0 {
+			statusCodes = DefaultRetryStatusCodes
+		}
+		ro.Retry = &RetryOption{Retry: retryFunc, MaxRetries: maxRetries, StatusCodes: statusCodes}
+		return nil
+	}
+}
+
+// SetRequestOptionCircuit set every attempt of the request runs in circuit name of
+// breaker, empty name uses the request host
+func (c *Client) SetRequestOptionCircuit(breaker CircuitBreaker, name string) SendClientOptions {
+	return func(ro *RequestOption) error {
+		if breaker == nil {
+			return errors.New(lib.StringTags("set request option", "circuit breaker is nil"))
+		}
+		ro.Circuit = breaker
+		ro.CircuitName = name
+		return nil
+	}
+}
+
+// do sends request, it is retried and run in circuit as configured by ro
+func (c *Client) do(ctx context.Context, client *http.Client, request *http.Request,
+	ro *RequestOption) (*http.Response, error) {
+	if ro.Retry == nil || !idempotent(request.Method) {
+		res, _, err := c.attempt(ctx, client, request, ro, 1)
+		return res, err
+	}
+	if request.Body != nil && request.GetBody == nil {
+		// buffer body so it is replayed by every attempt
+		data, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, lib.StringTags("client send", "buffer body"))
+		}
+		request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+		request.Body, _ = request.GetBody()
+	}
+	retryClient, err := retry.CreateClient(ro.Retry.Retry)
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("client send", "create retry"))
+	}
+	if err = retryClient.Init(retryClient.SetMaxRetriesOption(ro.Retry.MaxRetries)); err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("client send", "init retry"))
+	}
+	retryClient.C.Reset()
+	for n := 1; ; n++ {
+		if n > 1 && request.GetBody != nil {
+			if request.Body, err = request.GetBody(); err != nil {
+				return nil, errors.Wrap(err, lib.StringTags("client send", "replay body"))
+			}
+		}
+		res, ran, err := c.attempt(ctx, client, request, ro, n)
+		if !ran || !retryable(ctx, res, err, ro.Retry.StatusCodes) {
+			return res, err
+		}
+		wait := retryClient.C.NextBackOff()
+		if wait == backoff.Stop {
+			return res, err
+		}
+		if res != nil {
+			if after, ok := retryAfter(res); ok {
+				maxRetryAfter := ro.Retry.MaxRetryAfter
+				if maxRetryAfter <= 0 {
+					maxRetryAfter = defaultMaxRetryAfter
+				}
+				if after > maxRetryAfter {
+					// server is not expected back in time
+					return res, err
+				}
+				wait = after
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt sends request once in the circuit, ran reports whether it was sent
+func (c *Client) attempt(ctx context.Context, client *http.Client, request *http.Request,
+	ro *RequestOption, n int) (res *http.Response, ran bool, err error) {
+	defer func() {
+		if ran {
+			logAttempt(ctx, n, res, err)
+		}
+	}()
+	if ro.Circuit == nil {
+		res, err = client.Do(request)
+		return res, true, err
+	}
+	name := ro.CircuitName
+	if name == "" {
+		name = request.URL.Host
+	}
+	err = ro.Circuit.Execute(ctx, name, func(context.Context) error {
+		ran = true
+		var doErr error
+		if res, doErr = client.Do(request); doErr != nil {
+			return doErr
+		}
+		if res.StatusCode >= http.StatusInternalServerError {
+			return errServerStatus
+		}
+		return nil
+	}, nil)
+	if res != nil && err != nil && errors.Cause(err) != errServerStatus {
+		res.Body.Close()
+		res = nil
+	}
+	if res != nil {
+		err = nil
+	}
+	return res, ran, err
+}
+
+// logAttempt records attempt as an event of the request span
+func logAttempt(ctx context.Context, n int, res *http.Response, err error) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	fields := []otlog.Field{otlog.String("event", "http.attempt"), otlog.Int("attempt", n)}
+	if err != nil {
+		fields = append(fields, otlog.Error(err))
+	}
+	if res != nil {
+		fields = append(fields, otlog.Int("http.status_code", res.StatusCode))
+	}
+	span.LogFields(fields...)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(ctx context.Context, res *http.Response, err error, statusCodes []int) bool {
+	if err != nil {
+		// caller gave up, retrying can not succeed
+		return ctx.Err() == nil
+	}
+	for _, code := range statusCodes {
+		if res.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses Retry-After in seconds or as HTTP date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	value := res.Header.Get(HeaderRetryAfter)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,314 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hauxe/gom/retry"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTransport(t *testing.T) {
+	t.Parallel()
+	server := CreateSampleServer(ServerRoute{
+		Path: "/remote_addr",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.RemoteAddr))
+		},
+	})
+	client, err := CreateClient()
+	require.Nil(t, err)
+	// transport is created by the first request of a client which is not connected
+	resp, err := client.Send(context.Background(), http.MethodGet, server.URL+"/remote_addr")
+	require.Nil(t, err)
+	resp.Body.Close()
+	transport := client.Transport
+	require.NotNil(t, transport)
+	require.Equal(t, maxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	require.Nil(t, client.Connect())
+	require.Equal(t, transport, client.Transport)
+	remoteAddr := func() string {
+		resp, err := client.Send(context.Background(), http.MethodGet, server.URL+"/remote_addr")
+		require.Nil(t, err)
+		body, err := ReadBodyString(resp)
+		require.Nil(t, err)
+		return body
+	}
+
+	// sequential requests reuse the kept alive connection
+	first := remoteAddr()
+	for i := 0; i < 5; i++ {
+		require.Equal(t, first, remoteAddr())
+	}
+	require.Nil(t, client.Disconnect())
+	require.NotEqual(t, first, remoteAddr())
+
+	// transport of the request overrides the shared one
+	own := &http.Transport{}
+	resp, err = client.Send(context.Background(), http.MethodGet, server.URL+"/remote_addr",
+		client.SetRequestOptionTransport(own))
+	require.Nil(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+	require.NotEqual(t, first, string(body))
+	own.CloseIdleConnections()
+}
+
+func TestClientTransportOption(t *testing.T) {
+	t.Parallel()
+	client, err := CreateClient()
+	require.Nil(t, err)
+	transport := &http.Transport{}
+	require.Nil(t, client.Connect(client.SetTransportOption(transport)))
+	require.Equal(t, transport, client.Transport)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	for _, enabled := range []bool{false, true} {
+		client, err = CreateClient()
+		require.Nil(t, err)
+		client.Config.HTTP2 = enabled
+		require.Nil(t, client.Connect())
+		resp, err := client.Send(context.Background(), http.MethodGet, server.URL)
+		require.Nil(t, err)
+		proto, err := ReadBodyString(resp)
+		require.Nil(t, err)
+		expected := "HTTP/1.1"
+		if enabled {
+			expected = "HTTP/2.0"
+		}
+		require.Equal(t, expected, proto)
+		client.Disconnect()
+	}
+}
+
+type fakeCircuit struct {
+	names    []string
+	failures int
+}
+
+func (f *fakeCircuit) Execute(ctx context.Context, name string, runFunc func(context.Context) error,
+	_ func(context.Context, error) error) error {
+	f.names = append(f.names, name)
+	if f.failures >= 2 {
+		return errors.New("circuit open")
+	}
+	err := runFunc(ctx)
+	if err != nil {
+		f.failures++
+	}
+	return err
+}
+
+func TestClientRetry(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	server := CreateSampleServer(ServerRoute{
+		Path: "/flaky",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			n := atomic.AddInt32(&attempts, 1)
+			if n%3 != 0 {
+				w.Header().Set(HeaderRetryAfter, "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(body)
+		},
+	}, ServerRoute{
+		Path: "/busy",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.Header().Set(HeaderRetryAfter, "86400")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	})
+	client, err := CreateClient()
+	require.Nil(t, err)
+	require.Nil(t, client.Connect())
+	defer client.Disconnect()
+	body := func(ro *RequestOption) error {
+		// reader without length is buffered to be replayed
+		ro.Body = io.MultiReader(strings.NewReader("payload"))
+		return nil
+	}
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("send")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+
+	atomic.StoreInt32(&attempts, 0)
+	resp, err := client.Send(ctx, http.MethodPut, server.URL+"/flaky", body,
+		client.SetRequestOptionRetry(5, retry.UseConstantRetry(time.Millisecond)))
+	require.Nil(t, err)
+	data, err := ReadBodyString(resp)
+	require.Nil(t, err)
+	require.Equal(t, "payload", data)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	span.Finish()
+	logs := span.(*mocktracer.MockSpan).Logs()
+	require.Len(t, logs, 3)
+	require.Equal(t, "attempt", logs[2].Fields[1].Key)
+	require.Equal(t, "3", logs[2].Fields[1].ValueString)
+
+	// retries are exhausted, last response is returned
+	atomic.StoreInt32(&attempts, 0)
+	resp, err = client.Send(context.Background(), http.MethodGet, server.URL+"/flaky",
+		client.SetRequestOptionRetry(1, retry.UseConstantRetry(time.Millisecond)))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// Retry-After beyond the bound returns the response without waiting
+	atomic.StoreInt32(&attempts, 0)
+	start := time.Now()
+	resp, err = client.Send(context.Background(), http.MethodGet, server.URL+"/busy",
+		client.SetRequestOptionRetry(5, retry.UseConstantRetry(time.Millisecond)))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	require.True(t, time.Since(start) < time.Second)
+
+	// not idempotent method is sent once
+	atomic.StoreInt32(&attempts, 0)
+	resp, err = client.Send(context.Background(), http.MethodPost, server.URL+"/flaky", body,
+		client.SetRequestOptionRetry(5, nil))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// open circuit stops retries
+	breaker := &fakeCircuit{}
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.Send(context.Background(), http.MethodGet, server.URL+"/flaky",
+		client.SetRequestOptionRetry(5, retry.UseConstantRetry(time.Millisecond)),
+		client.SetRequestOptionCircuit(breaker, ""))
+	require.NotNil(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	host := strings.TrimPrefix(server.URL, "http://")
+	require.Equal(t, []string{host, host, host}, breaker.names)
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+	res := &http.Response{Header: http.Header{}}
+	_, ok := retryAfter(res)
+	require.False(t, ok)
+	res.Header.Set(HeaderRetryAfter, "2")
+	wait, ok := retryAfter(res)
+	require.True(t, ok)
+	require.Equal(t, 2*time.Second, wait)
+	res.Header.Set(HeaderRetryAfter, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	wait, ok = retryAfter(res)
+	require.True(t, ok)
+	require.True(t, wait > 59*time.Minute)
+	res.Header.Set(HeaderRetryAfter, "soon")
+	_, ok = retryAfter(res)
+	require.False(t, ok)
+}
+
+func TestClientJSON(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	server := CreateSampleServer(
+		ServerRoute{
+			Path: "/items",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				req := item{}
+				if r.Method == http.MethodGet {
+					req = item{Name: "got", Count: 1}
+				} else if err := ParseParameters(r, &req); err != nil {
+					SendError(w, err)
+					return
+				}
+				if req.Count < 0 {
+					SendError(w, ValidationError{FieldErrors{"count": "must be at least 0"}})
+					return
+				}
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, "success",
+					map[string]interface{}{"success": req})
+			},
+		},
+		ServerRoute{
+			Path: "/empty",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+		ServerRoute{
+			Path: "/failed",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				SendResponse(w, http.StatusOK, ErrorCodeFailed, "failed", nil)
+			},
+		},
+		ServerRoute{
+			Path: "/proxy",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "<html>bad gateway</html>", http.StatusBadGateway)
+			},
+		})
+	client, err := CreateClient()
+	require.Nil(t, err)
+	require.Nil(t, client.Connect())
+	defer client.Disconnect()
+	ctx := context.Background()
+
+	got := item{}
+	require.Nil(t, client.GetJSON(ctx, server.URL+"/items", &got))
+	require.Equal(t, item{Name: "got", Count: 1}, got)
+	created := item{}
+	require.Nil(t, client.PostJSON(ctx, server.URL+"/items", item{Name: "new", Count: 2}, &created))
+	require.Equal(t, item{Name: "new", Count: 2}, created)
+	require.Nil(t, client.PutJSON(ctx, server.URL+"/items", item{Name: "new"}, nil))
+
+	err = client.PostJSON(ctx, server.URL+"/items", item{Name: "new", Count: -1}, &created)
+	responseErr, ok := err.(*ResponseError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusBadRequest, responseErr.Status())
+	require.Equal(t, ErrorCodeValidationFailed, responseErr.ErrorCode())
+	fieldErrors := FieldErrors{}
+	require.Nil(t, responseErr.DecodeData(&fieldErrors))
+	require.Equal(t, FieldErrors{"count": "must be at least 0"}, fieldErrors)
+	// forwarded error keeps status and error code
+	status, code := statusOf(responseErr)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, ErrorCodeValidationFailed, code)
+
+	err = client.GetJSON(ctx, server.URL+"/proxy", &got)
+	responseErr, ok = err.(*ResponseError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusBadGateway, responseErr.StatusCode)
+	require.Equal(t, ErrorCodeFailed, responseErr.Code)
+	require.NotNil(t, responseErr.DecodeData(&fieldErrors))
+
+	got = item{}
+	require.Nil(t, client.DeleteJSON(ctx, server.URL+"/empty", &got))
+	require.Equal(t, item{}, got)
+
+	err = client.GetJSON(ctx, server.URL+"/failed", &got)
+	responseErr, ok = err.(*ResponseError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusBadGateway, responseErr.Status())
+	require.Equal(t, ErrorCodeFailed, responseErr.ErrorCode())
+	require.Equal(t, "failed", responseErr.Message)
+}
@@ -1,5 +1,7 @@
 package http
 
+import lib "github.com/hauxe/gom/library"
+
 // ErrorCode type error code
 type ErrorCode int
 
@@ -12,32 +14,76 @@ const (
 	ErrorCodeMalformedMethod
 	ErrorCodeBadRequest
 	ErrorCodeValidationFailed
+	ErrorCodeServiceUnavailable
+	ErrorCodeTooManyRequests
+	ErrorCodeUnauthorized
+	ErrorCodeForbidden
+	ErrorCodeRequestTooLarge
 )
 
 // HTTP headers
 const (
-	HeaderOrigin           = "Origin"
-	HeaderAccept           = "Accept"
-	HeaderContentType      = "Content-Type"
-	HeaderAuthorization    = "Authorization"
-	HeaderAllowOrigin      = "Access-Control-Allow-Origin"
-	HeaderAllowMethods     = "Access-Control-Allow-Methods"
-	HeaderAllowHeaders     = "Access-Control-Allow-Headers"
-	HeaderExposeHeaders    = "Access-Control-Expose-Headers"
-	HeaderAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderOrigin             = "Origin"
+	HeaderAccept             = "Accept"
+	HeaderContentType        = "Content-Type"
+	HeaderAuthorization      = "Authorization"
+	HeaderAllowOrigin        = "Access-Control-Allow-Origin"
+	HeaderAllowMethods       = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders       = "Access-Control-Allow-Headers"
+	HeaderExposeHeaders      = "Access-Control-Expose-Headers"
+	HeaderAllowCredentials   = "Access-Control-Allow-Credentials"
+	HeaderMaxAge             = "Access-Control-Max-Age"
+	HeaderRequestMethod      = "Access-Control-Request-Method"
+	HeaderRequestHeaders     = "Access-Control-Request-Headers"
+	HeaderAllow              = "Allow"
+	HeaderVary               = "Vary"
+	HeaderRequestID          = lib.HeaderRequestID
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderWWWAuthenticate    = "WWW-Authenticate"
+	HeaderAPIKey             = "X-Api-Key"
+	HeaderSignature          = "X-Signature"
+	HeaderSignatureKey       = "X-Signature-Key"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderAcceptEncoding     = "Accept-Encoding"
+	HeaderContentEncoding    = "Content-Encoding"
+	HeaderContentLength      = "Content-Length"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderLastEventID        = "Last-Event-ID"
 )
 
 // Content types
 const (
-	ContentTypeJSON = "application/json"
-	ContentTypeHTML = "text/html"
-	ContentTypeText = "text/plain"
-	ContentTypeForm = "application/x-www-form-urlencoded"
+	ContentTypeJSON        = "application/json"
+	ContentTypeHTML        = "text/html"
+	ContentTypeText        = "text/plain"
+	ContentTypeForm        = "application/x-www-form-urlencoded"
+	ContentTypeXML         = "application/xml"
+	ContentTypeMsgPack     = "application/msgpack"
+	ContentTypeMultipart   = "multipart/form-data"
+	ContentTypeEventStream = "text/event-stream"
 )
 
 type contextValidator string
 
+type contextPathParams string
+
+type contextRoute string
+
+type contextPrincipal string
+
+type contextUpload string
+
+type contextConn string
+
 // defines context key
 const (
-	ContextValidatorKey contextValidator = "validator"
+	ContextValidatorKey  contextValidator  = "validator"
+	ContextPathParamsKey contextPathParams = "path_params"
+	ContextRouteKey      contextRoute      = "route"
+	ContextPrincipalKey  contextPrincipal  = "principal"
+	ContextUploadKey     contextUpload     = "upload"
+	ContextConnKey       contextConn       = "conn"
 )
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	lib "github.com/hauxe/gom/library"
+)
+
+const corsWildcard = "*"
+
+// CORSConfig defines cross-origin resource sharing policy
+type CORSConfig struct {
+	// AllowedOrigins accepts exact origins, "*" for any origin or patterns
+	// with one wildcard such as https://*.example.com
+	AllowedOrigins []string `env:"HTTP_SERVER_CORS_ALLOWED_ORIGINS"`
+	// AllowOriginFunc is checked before AllowedOrigins when it is set
+	AllowOriginFunc  func(origin string) bool
+	AllowedMethods   []string `env:"HTTP_SERVER_CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string `env:"HTTP_SERVER_CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string `env:"HTTP_SERVER_CORS_EXPOSED_HEADERS"`
+	MaxAge           int      `env:"HTTP_SERVER_CORS_MAX_AGE"`
+	AllowCredentials bool     `env:"HTTP_SERVER_CORS_ALLOW_CREDENTIALS"`
+}
+
+// DefaultCORSConfig returns the policy allowing any origin without credentials
+func DefaultCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		AllowedOrigins: []string{corsWildcard},
+		AllowedMethods: append([]string{}, httpMethods...),
+		AllowedHeaders: []string{
+			HeaderContentType,
+			HeaderAuthorization,
+			HeaderOrigin,
+			HeaderAccept,
+		},
+		ExposedHeaders: []string{HeaderContentType},
+	}
+}
+
+// SetCORSOption set http server global cors policy, nil disables cors headers
+func (s *Server) SetCORSOption(config *CORSConfig) StartServerOptions {
+	return func() (err error) {
+		s.Config.CORS = config
+		return nil
+	}
+}
+
+// corsMiddleware applies the policy of the route matching the request, or the global
+// one, before any other middleware so their error responses carry cors headers and
+// preflight requests are answered before authentication
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// preflight request asks for the method it is going to send
+		method := r.Method
+		preflight := r.Method == http.MethodOptions && r.Header.Get(HeaderRequestMethod) != ""
+		if preflight {
+			method = r.Header.Get(HeaderRequestMethod)
+		}
+		pattern, _, _ := s.Router.Match(r.URL.EscapedPath())
+		s.routesMux.RLock()
+		routes, existed := s.Routes[pattern]
+		cors := s.Config.CORS
+		if definition := s.definitions[pattern][method]; definition.CORS != nil {
+			cors = definition.CORS
+		}
+		methods := s.routeMethods(pattern)
+		_, hasOptions := routes[http.MethodOptions]
+		s.routesMux.RUnlock()
+		if !existed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cors != nil && !cors.apply(w, r, methods, preflight) && preflight {
+			SendResponse(w, http.StatusForbidden, ErrorCodeFailed, "origin is not allowed", nil)
+			return
+		}
+		if preflight {
+			if !hasOptions {
+				methods = append(methods, http.MethodOptions)
+			}
+			w.Header().Set(HeaderAllow, lib.JoinWithComma(methods))
+			SendResponse(w, http.StatusOK, ErrorCodeSuccess, "ok", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchOrigin reports whether origin is allowed and whether it is allowed by wildcard
+func (c *CORSConfig) matchOrigin(origin string) (allowed bool, wildcard bool) {
+	if c.AllowOriginFunc != nil && c.AllowOriginFunc(origin) {
+		return true, false
+	}
+	for _, allowedOrigin := range c.AllowedOrigins {
+		if allowedOrigin == corsWildcard {
+			wildcard = true
+			continue
+		}
+		i := strings.Index(allowedOrigin, corsWildcard)
+		if i < 0 {
+			if strings.EqualFold(allowedOrigin, origin) {
+				return true, false
+			}
+			continue
+		}
+		prefix, suffix := allowedOrigin[:i], allowedOrigin[i+1:]
+		if len(origin) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true, false
+		}
+	}
+	return wildcard, wildcard
+}
+
+func (c *CORSConfig) allowAnyHeader() bool {
+	for _, allowedHeader := range c.AllowedHeaders {
+		if allowedHeader == corsWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedMethods filters route methods by the policy
+func (c *CORSConfig) allowedMethods(methods []string) []string {
+	if len(c.AllowedMethods) == 0 {
+		return methods
+	}
+	allowed := make([]string, 0, len(methods))
+	for _, method := range methods {
+		for _, allowedMethod := range c.AllowedMethods {
+			if allowedMethod == corsWildcard || strings.EqualFold(allowedMethod, method) {
+				allowed = append(allowed, method)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// apply sets cors response headers, it returns false when origin is not allowed.
+// Wildcard origin is never combined with credentials since browsers reject it
+func (c *CORSConfig) apply(w http.ResponseWriter, r *http.Request, methods []string, preflight bool) bool {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		// same origin or non browser request
+		return true
+	}
+	header := w.Header()
+	allowed, wildcard := c.matchOrigin(origin)
+	if !wildcard {
+		header.Add(HeaderVary, HeaderOrigin)
+	}
+	if !allowed {
+		return false
+	}
+	if wildcard {
+		header.Set(HeaderAllowOrigin, corsWildcard)
+	} else {
+		header.Set(HeaderAllowOrigin, origin)
+		if c.AllowCredentials {
+			header.Set(HeaderAllowCredentials, "true")
+		}
+	}
+	if !preflight {
+		if len(c.ExposedHeaders) > 0 {
+			header.Set(HeaderExposeHeaders, lib.JoinWithComma(c.ExposedHeaders))
+		}
+		return true
+	}
+	header.Set(HeaderAllowMethods, lib.JoinWithComma(c.allowedMethods(methods)))
+	if c.allowAnyHeader() {
+		// echo requested headers
+		if requestHeaders := r.Header.Get(HeaderRequestHeaders); requestHeaders != "" {
+			header.Set(HeaderAllowHeaders, requestHeaders)
+		}
+	} else if len(c.AllowedHeaders) > 0 {
+		header.Set(HeaderAllowHeaders, lib.JoinWithComma(c.AllowedHeaders))
+	}
+	if c.MaxAge > 0 {
+		header.Set(HeaderMaxAge, strconv.Itoa(c.MaxAge))
+	}
+	return true
+}
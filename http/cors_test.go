@@ -0,0 +1,170 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSMatchOrigin(t *testing.T) {
+	t.Parallel()
+	config := &CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc: func(origin string) bool {
+			return origin == "http://localhost:3000"
+		},
+	}
+	testCases := []struct {
+		Origin   string
+		Allowed  bool
+		Wildcard bool
+	}{
+		{"https://app.example.com", true, false},
+		{"HTTPS://APP.EXAMPLE.COM", true, false},
+		{"https://api.example.org", true, false},
+		{"https://example.org", false, false},
+		{"http://localhost:3000", true, false},
+		{"https://evil.com", false, false},
+	}
+	for _, tc := range testCases {
+		allowed, wildcard := config.matchOrigin(tc.Origin)
+		require.Equal(t, tc.Allowed, allowed, tc.Origin)
+		require.Equal(t, tc.Wildcard, wildcard, tc.Origin)
+	}
+	allowed, wildcard := DefaultCORSConfig().matchOrigin("https://evil.com")
+	require.True(t, allowed)
+	require.True(t, wildcard)
+}
+
+func TestServerCORS(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	routeCORS := &CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"X-Total"},
+		MaxAge:           600,
+		AllowCredentials: true,
+	}
+	err = server.Start(server.SetHostPortOption("localhost", 18003),
+		server.SetHandlerOption(
+			ServerRoute{Name: "list", Method: http.MethodGet, Path: "/cors", Handler: handler},
+			ServerRoute{Name: "create", Method: http.MethodPost, Path: "/cors", Handler: handler},
+			ServerRoute{Name: "private", Method: http.MethodDelete, Path: "/cors", Handler: handler,
+				CORS: routeCORS},
+		),
+		server.SetCORSOption(&CORSConfig{
+			AllowedOrigins:   []string{"*"},
+			AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+			AllowedHeaders:   []string{HeaderContentType},
+			AllowCredentials: true,
+		}),
+		// rejects requests without credentials, preflights included
+		server.SetMiddlewareOption(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodOptions || r.Header.Get("X-Reject") != "" {
+					SendError(w, UnauthorizedError{errors.New("authentication is required")})
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+	send := func(method, origin string, header map[string]string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+"/cors", nil)
+		require.Nil(t, err)
+		if origin != "" {
+			req.Header.Set(HeaderOrigin, origin)
+		}
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := hc.Do(req)
+		require.Nil(t, err)
+		return resp
+	}
+
+	t.Run("no origin", func(t *testing.T) {
+		resp := send(http.MethodGet, "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(HeaderAllowOrigin))
+	})
+
+	t.Run("global wildcard without credentials", func(t *testing.T) {
+		resp := send(http.MethodGet, "https://any.com", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "*", resp.Header.Get(HeaderAllowOrigin))
+		require.Empty(t, resp.Header.Get(HeaderAllowCredentials))
+	})
+
+	t.Run("global preflight advertises registered methods", func(t *testing.T) {
+		resp := send(http.MethodOptions, "https://any.com", map[string]string{
+			HeaderRequestMethod: http.MethodPost,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "*", resp.Header.Get(HeaderAllowOrigin))
+		require.Equal(t, "DELETE, GET, POST", resp.Header.Get(HeaderAllowMethods))
+		require.Equal(t, HeaderContentType, resp.Header.Get(HeaderAllowHeaders))
+		require.Empty(t, resp.Header.Get(HeaderMaxAge))
+	})
+
+	t.Run("route preflight", func(t *testing.T) {
+		resp := send(http.MethodOptions, "https://app.example.com", map[string]string{
+			HeaderRequestMethod:  http.MethodDelete,
+			HeaderRequestHeaders: "X-Custom",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "https://app.example.com", resp.Header.Get(HeaderAllowOrigin))
+		require.Equal(t, "true", resp.Header.Get(HeaderAllowCredentials))
+		require.Equal(t, "X-Custom", resp.Header.Get(HeaderAllowHeaders))
+		require.Equal(t, "600", resp.Header.Get(HeaderMaxAge))
+		require.Equal(t, HeaderOrigin, resp.Header.Get(HeaderVary))
+	})
+
+	t.Run("route preflight origin not allowed", func(t *testing.T) {
+		resp := send(http.MethodOptions, "https://evil.com", map[string]string{
+			HeaderRequestMethod: http.MethodDelete,
+		})
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(HeaderAllowOrigin))
+	})
+
+	t.Run("route request", func(t *testing.T) {
+		resp := send(http.MethodDelete, "https://app.example.com", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "https://app.example.com", resp.Header.Get(HeaderAllowOrigin))
+		require.Equal(t, "X-Total", resp.Header.Get(HeaderExposeHeaders))
+	})
+
+	t.Run("middleware error", func(t *testing.T) {
+		resp := send(http.MethodGet, "https://any.com", map[string]string{"X-Reject": "1"})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, "*", resp.Header.Get(HeaderAllowOrigin))
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		resp := send(http.MethodPut, "", nil)
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		require.Equal(t, "DELETE, GET, POST", resp.Header.Get(HeaderAllow))
+	})
+}
+
+func TestCORSApplyDisabledHeaders(t *testing.T) {
+	t.Parallel()
+	config := &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderOrigin, "https://evil.com")
+	require.False(t, config.apply(w, r, nil, false))
+	require.Empty(t, w.Header().Get(HeaderAllowOrigin))
+	require.Equal(t, HeaderOrigin, w.Header().Get(HeaderVary))
+}
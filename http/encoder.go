@@ -0,0 +1,355 @@
+package http
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MarshalFunc encodes a response value
+type MarshalFunc func(v interface{}) ([]byte, error)
+
+type responseEncoder struct {
+	contentType string
+	marshal     MarshalFunc
+}
+
+// response encoders in preference order, the first one is the default
+var (
+	encoders = []responseEncoder{
+		{ContentTypeJSON, json.Marshal},
+		{ContentTypeMsgPack, MarshalMsgPack},
+		{ContentTypeXML, MarshalXML},
+	}
+	encodersMux sync.RWMutex
+)
+
+// RegisterEncoder registers response encoder of a content type, an existing encoder
+// of the content type is replaced
+func RegisterEncoder(contentType string, marshal MarshalFunc) {
+	encodersMux.Lock()
+	defer encodersMux.Unlock()
+	for i := range encoders {
+		if encoders[i].contentType == contentType {
+			encoders[i].marshal = marshal
+			return
+		}
+	}
+	encoders = append(encoders, responseEncoder{contentType, marshal})
+}
+
+// NegotiateContentType returns the registered content type the Accept header prefers,
+// ties are broken by registration order and JSON is returned when nothing matches
+func NegotiateContentType(accept string) string {
+	encodersMux.RLock()
+	defer encodersMux.RUnlock()
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return ContentTypeJSON
+	}
+	best, bestQ := ContentTypeJSON, 0.0
+	for _, encoder := range encoders {
+		if q := acceptQuality(ranges, encoder.contentType); q > bestQ {
+			best, bestQ = encoder.contentType, q
+		}
+	}
+	return best
+}
+
+func encoderOf(contentType string) MarshalFunc {
+	encodersMux.RLock()
+	defer encodersMux.RUnlock()
+	for _, encoder := range encoders {
+		if encoder.contentType == contentType {
+			return encoder.marshal
+		}
+	}
+	return json.Marshal
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType, q})
+	}
+	return ranges
+}
+
+// acceptQuality returns quality of the most specific range matching content type
+func acceptQuality(ranges []acceptRange, contentType string) float64 {
+	q, specificity := 0.0, -1
+	mainType := strings.SplitN(contentType, "/", 2)[0]
+	for _, r := range ranges {
+		s := -1
+		switch r.mediaType {
+		case contentType:
+			s = 2
+		case mainType + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// negotiatedWriter carries the content type negotiated for SendResponse
+type negotiatedWriter struct {
+	*responseWriter
+	contentType string
+}
+
+// NegotiationMiddleware negotiates the Accept header of the request so SendResponse
+// renders the response in the preferred registered content type
+func NegotiationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get(HeaderAccept)
+		if accept == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&negotiatedWriter{
+			responseWriter: newResponseWriter(w),
+			contentType:    NegotiateContentType(accept),
+		}, r)
+	})
+}
+
+// writerUnwrapper is implemented by response writer wrappers of this package
+type writerUnwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// negotiatedContentType finds the negotiated content type under writer wrappers
+func negotiatedContentType(w http.ResponseWriter) (string, bool) {
+	for w != nil {
+		if nw, ok := w.(*negotiatedWriter); ok {
+			return nw.contentType, true
+		}
+		unwrapper, ok := w.(writerUnwrapper)
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	return ContentTypeJSON, false
+}
+
+// genericValue converts v to the json data model so every encoder shares the
+// json field names of the response
+func genericValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	err = decoder.Decode(&value)
+	return value, err
+}
+
+// MarshalXML encodes v as xml under a response root element, objects become
+// elements named by their json keys and array items become item elements
+func MarshalXML(v interface{}) ([]byte, error) {
+	value, err := genericValue(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBufferString(xml.Header)
+	if err = writeXML(buf, "response", value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeXML(buf *bytes.Buffer, name string, value interface{}) error {
+	// keys which are not valid element names are kept in an attribute
+	if validXMLName(name) {
+		buf.WriteString("<" + name + ">")
+	} else {
+		buf.WriteString(`<entry key="`)
+		if err := xml.EscapeText(buf, []byte(name)); err != nil {
+			return err
+		}
+		buf.WriteString(`">`)
+		name = "entry"
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			if err := writeXML(buf, key, value[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := writeXML(buf, "item", item); err != nil {
+				return err
+			}
+		}
+	case string:
+		if err := xml.EscapeText(buf, []byte(value)); err != nil {
+			return err
+		}
+	case json.Number:
+		buf.WriteString(value.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	}
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case i > 0 && (c == '-' || c == '.' || (c >= '0' && c <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalMsgPack encodes v as MessagePack using the json data model
+func MarshalMsgPack(v interface{}) ([]byte, error) {
+	value, err := genericValue(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err = writeMsgPack(buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMsgPack(buf *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if value {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			writeMsgPackInt(buf, n)
+			return nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeMsgPackHeader(buf, len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(value)
+	case []interface{}:
+		writeMsgPackHeader(buf, len(value), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := writeMsgPack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeMsgPackHeader(buf, len(value), 0x80, 16, 0, 0xde, 0xdf)
+		for _, key := range sortedKeys(value) {
+			writeMsgPackHeader(buf, len(key), 0xa0, 32, 0xd9, 0xda, 0xdb)
+			buf.WriteString(key)
+			if err := writeMsgPack(buf, value[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeMsgPackHeader writes the fix format when size is below fixLimit, otherwise
+// the smallest of 8, 16 and 32 bits formats, a zero format is not available
+func writeMsgPackHeader(buf *bytes.Buffer, size int, fix byte, fixLimit int, f8, f16, f32 byte) {
+	switch {
+	case size < fixLimit:
+		buf.WriteByte(fix | byte(size))
+	case f8 != 0 && size <= math.MaxUint8:
+		buf.Write([]byte{f8, byte(size)})
+	case size <= math.MaxUint16:
+		buf.WriteByte(f16)
+		binary.Write(buf, binary.BigEndian, uint16(size))
+	default:
+		buf.WriteByte(f32)
+		binary.Write(buf, binary.BigEndian, uint32(size))
+	}
+}
+
+func writeMsgPackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n < 128, n < 0 && n >= -32:
+		buf.WriteByte(byte(n))
+	case n > 0 && n <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(n)})
+	case n > 0 && n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n > 0 && n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	case n > 0:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, uint64(n))
+	case n >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(n)})
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		Accept   string
+		Expected string
+	}{
+		{"", ContentTypeJSON},
+		{"*/*", ContentTypeJSON},
+		{"application/xml", ContentTypeXML},
+		{"application/msgpack", ContentTypeMsgPack},
+		{"application/xml;q=0.5, application/msgpack;q=0.8", ContentTypeMsgPack},
+		{"application/*;q=0.2, application/xml", ContentTypeXML},
+		{"text/html, application/xml;q=0.9", ContentTypeXML},
+		{"*/*;q=0.1, application/json;q=0", ContentTypeMsgPack},
+		{"text/html", ContentTypeJSON},
+		{"invalid;;", ContentTypeJSON},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.Expected, NegotiateContentType(tc.Accept), tc.Accept)
+	}
+}
+
+func TestMarshalMsgPack(t *testing.T) {
+	t.Parallel()
+	data, err := MarshalMsgPack(map[string]interface{}{
+		"a": 1,
+		"b": []interface{}{true, nil, -1, 200, -200, 1.5},
+		"c": "x",
+	})
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x83,
+		0xa1, 'a', 0x01,
+		0xa1, 'b', 0x96, 0xc3, 0xc0, 0xff, 0xcc, 0xc8, 0xd1, 0xff, 0x38,
+		0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa1, 'c', 0xa1, 'x',
+	}, data)
+
+	data, err = MarshalMsgPack(strings.Repeat("x", 40))
+	require.Nil(t, err)
+	require.Equal(t, []byte{0xd9, 40}, data[:2])
+	require.Len(t, data, 42)
+}
+
+func TestMarshalXML(t *testing.T) {
+	t.Parallel()
+	data, err := MarshalXML(map[string]interface{}{
+		"name":  "a<b",
+		"items": []int{1, 2},
+		"1 key": true,
+		"empty": nil,
+	})
+	require.Nil(t, err)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><entry key="1 key">true</entry><empty></empty>`+
+		`<items><item>1</item><item>2</item></items><name>a&lt;b</name></response>`,
+		string(data))
+}
+
+func TestSendResponseNegotiation(t *testing.T) {
+	t.Parallel()
+	handler := NegotiationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// wrappers of the package keep the negotiated content type reachable
+		SendResponse(newResponseWriter(w), http.StatusOK, ErrorCodeSuccess, "ok",
+			map[string]interface{}{"success": map[string]interface{}{"id": 1}})
+	}))
+	send := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			r.Header.Set(HeaderAccept, accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("")
+	require.Equal(t, ContentTypeJSON, w.Header().Get(HeaderContentType))
+	require.Empty(t, w.Header().Get(HeaderVary))
+	dest := ServerResponse{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest))
+	require.Equal(t, "ok", dest.ErrorMessage)
+
+	w = send("application/xml")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, ContentTypeXML, w.Header().Get(HeaderContentType))
+	require.Equal(t, HeaderAccept, w.Header().Get(HeaderVary))
+	require.Contains(t, w.Body.String(),
+		"<data><success><id>1</id></success></data>"+
+			"<error_code>0</error_code><error_message>ok</error_message>")
+
+	w = send("application/msgpack")
+	require.Equal(t, ContentTypeMsgPack, w.Header().Get(HeaderContentType))
+	require.Equal(t, byte(0x84), w.Body.Bytes()[0])
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	t.Parallel()
+	contentType := "application/vnd.test"
+	RegisterEncoder(contentType, func(v interface{}) ([]byte, error) {
+		return []byte("test"), nil
+	})
+	require.Equal(t, contentType, NegotiateContentType(contentType))
+	data, err := encoderOf(contentType)(nil)
+	require.Nil(t, err)
+	require.Equal(t, "test", string(data))
+}
@@ -1,11 +1,179 @@
 package http
 
+import (
+	"net/http"
+	"strings"
+)
+
+// StatusError is implemented by errors declaring the response status and error code
+// SendError replies with
+type StatusError interface {
+	error
+	Status() int
+	ErrorCode() ErrorCode
+}
+
+// DetailedError is implemented by errors carrying details SendError puts in the
+// error data of the response
+type DetailedError interface {
+	error
+	Details() interface{}
+}
+
+// Error defines an error with its response status and error code
+type Error struct {
+	StatusCode int
+	Code       ErrorCode
+	Err        error
+}
+
+func (e Error) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Err.Error()
+}
+
+// Status returns response status
+func (e Error) Status() int {
+	return e.StatusCode
+}
+
+// ErrorCode returns response error code
+func (e Error) ErrorCode() ErrorCode {
+	return e.Code
+}
+
+// Cause returns the underlying error
+func (e Error) Cause() error {
+	return e.Err
+}
+
 // BadRequestError define http bad request error
 type BadRequestError struct {
 	error
 }
 
+// Status returns http.StatusBadRequest
+func (BadRequestError) Status() int {
+	return http.StatusBadRequest
+}
+
+// ErrorCode returns ErrorCodeBadRequest
+func (BadRequestError) ErrorCode() ErrorCode {
+	return ErrorCodeBadRequest
+}
+
 // ValidationError define http validation error
 type ValidationError struct {
 	error
 }
+
+// Status returns http.StatusBadRequest
+func (ValidationError) Status() int {
+	return http.StatusBadRequest
+}
+
+// ErrorCode returns ErrorCodeValidationFailed
+func (ValidationError) ErrorCode() ErrorCode {
+	return ErrorCodeValidationFailed
+}
+
+// Details returns invalid fields of struct tag validation
+func (e ValidationError) Details() interface{} {
+	if fieldErrors, ok := e.error.(FieldErrors); ok {
+		return fieldErrors
+	}
+	return nil
+}
+
+// UnauthorizedError define http unauthorized error
+type UnauthorizedError struct {
+	error
+}
+
+// Status returns http.StatusUnauthorized
+func (UnauthorizedError) Status() int {
+	return http.StatusUnauthorized
+}
+
+// ErrorCode returns ErrorCodeUnauthorized
+func (UnauthorizedError) ErrorCode() ErrorCode {
+	return ErrorCodeUnauthorized
+}
+
+// ForbiddenError define http forbidden error
+type ForbiddenError struct {
+	error
+}
+
+// Status returns http.StatusForbidden
+func (ForbiddenError) Status() int {
+	return http.StatusForbidden
+}
+
+// ErrorCode returns ErrorCodeForbidden
+func (ForbiddenError) ErrorCode() ErrorCode {
+	return ErrorCodeForbidden
+}
+
+// RequestTooLargeError define http request entity too large error
+type RequestTooLargeError struct {
+	error
+}
+
+// Status returns http.StatusRequestEntityTooLarge
+func (RequestTooLargeError) Status() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// ErrorCode returns ErrorCodeRequestTooLarge
+func (RequestTooLargeError) ErrorCode() ErrorCode {
+	return ErrorCodeRequestTooLarge
+}
+
+// statusOf returns status and error code declared by err or the errors it wraps,
+// other errors and invalid statuses are internal errors
+func statusOf(err error) (int, ErrorCode) {
+	for ; err != nil; err = causeOf(err) {
+		if statusErr, ok := err.(StatusError); ok {
+			status := statusErr.Status()
+			if status < 100 || status > 999 {
+				break
+			}
+			return status, statusErr.ErrorCode()
+		}
+	}
+	return http.StatusInternalServerError, ErrorCodeInternalError
+}
+
+// detailsOf returns details of the first DetailedError in the error chain
+func detailsOf(err error) interface{} {
+	for ; err != nil; err = causeOf(err) {
+		if detailedErr, ok := err.(DetailedError); ok {
+			return detailedErr.Details()
+		}
+	}
+	return nil
+}
+
+// causeOf returns the error wrapped by err, nil when it wraps nothing
+func causeOf(err error) error {
+	if causer, ok := err.(interface {
+		Cause() error
+	}); ok {
+		return causer.Cause()
+	}
+	return nil
+}
+
+// StopErrors collects errors occurred while stopping server
+type StopErrors []error
+
+func (errs StopErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
@@ -7,7 +7,12 @@ import (
 
 // GetFileSystemHandler get the file system http handler
 func GetFileSystemHandler(directory, path string) http.HandlerFunc {
-	fileServer := http.FileServer(FileSystem{http.Dir(directory)})
+	return GetFileSystemHandlerFS(http.Dir(directory), path)
+}
+
+// GetFileSystemHandlerFS get the http handler of a file system
+func GetFileSystemHandlerFS(fs http.FileSystem, path string) http.HandlerFunc {
+	fileServer := http.FileServer(FileSystem{fs})
 	return http.StripPrefix(strings.TrimRight(path, "/"), fileServer).ServeHTTP
 }
 
@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"mime"
 	"net/http"
 	"time"
 
@@ -19,16 +20,6 @@ var (
 		http.MethodDelete,
 		http.MethodOptions,
 	}
-	allowOrigins     = "*"
-	allowCredentials = "true"
-	allowMethods     = lib.JoinWithComma(httpMethods)
-	allowHeaders     = lib.JoinWithComma([]string{
-		HeaderContentType,
-		HeaderAuthorization,
-		HeaderOrigin,
-		HeaderAccept,
-	})
-	exposeHeaders = lib.JoinWithComma([]string{HeaderContentType})
 )
 
 // ParamValidator route param validator type
@@ -36,11 +27,20 @@ type ParamValidator func(context.Context, interface{}) error
 
 // ServerRoute defines route
 type ServerRoute struct {
-	Name       string
-	Method     string
-	Path       string
-	Validators []ParamValidator
-	Handler    http.HandlerFunc
+	Name         string
+	Method       string
+	Path         string
+	Validators   []ParamValidator
+	Middlewares  []Middleware
+	CORS         *CORSConfig      // overrides the server cors policy
+	RateLimit    *RateLimit       // applies before route middlewares
+	Auth         *AuthRequirement // requires an authenticated principal
+	Upload       *UploadConfig    // overrides DefaultUploadConfig
+	WriteTimeout time.Duration    // overrides the server write timeout, negative disables it
+	WebSocket    *WebSocketRoute  // upgrades requests to websocket instead of Handler
+	Request      interface{}      // request type documented by OpenAPI
+	Response     interface{}      // success data type documented by OpenAPI
+	Handler      http.HandlerFunc
 }
 
 // ServerResponseData defines server response data type, we have 3 type
@@ -58,11 +58,19 @@ type ServerResponse struct {
 	Time         *lib.TimeRFC3339   `json:"time"`
 }
 
-// ParseParameters parses parameters from request body or query
+// ParseParameters parses parameters from request body or query, path parameters
+// captured by the route pattern are decoded into fields tagged with `path` and
+// multipart files into fields of type *UploadedFile. The result is validated by its
+// `validate` tags, see ValidateStruct, then by the route validators
 func ParseParameters(r *http.Request, dst interface{}) error {
 	var err error
-	defer r.Body.Close()
-	switch r.Header.Get(HeaderContentType) {
+	// invalid or missing content type is parsed from query
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(HeaderContentType))
+	if mediaType != ContentTypeMultipart || !uploadConfigOf(r).Stream {
+		// streamed body is read by the handler after parsing
+		defer r.Body.Close()
+	}
+	switch mediaType {
 	case ContentTypeForm:
 		err = r.ParseForm()
 		if err != nil {
@@ -74,6 +82,16 @@ func ParseParameters(r *http.Request, dst interface{}) error {
 		// numbers are represented as string instead of float64
 		decoder.UseNumber()
 		err = decoder.Decode(dst)
+	case ContentTypeMultipart:
+		if err = parseMultipartForm(r); err != nil {
+			return err
+		}
+		if r.MultipartForm != nil {
+			err = decoder.Decode(dst, r.Form)
+		} else {
+			// streamed body is left to the handler
+			err = decoder.Decode(dst, r.URL.Query())
+		}
 	default:
 		// parse data from query
 		err = decoder.Decode(dst, r.URL.Query())
@@ -81,6 +99,18 @@ func ParseParameters(r *http.Request, dst interface{}) error {
 	if err != nil {
 		return BadRequestError{err}
 	}
+	if err = decodePathParams(r, dst); err != nil {
+		return BadRequestError{err}
+	}
+	if err = decodeUploadedFiles(r, dst); err != nil {
+		return err
+	}
+	if err = ValidateStruct(dst); err != nil {
+		if _, ok := err.(FieldErrors); ok {
+			return ValidationError{err}
+		}
+		return err
+	}
 	// validate parameters
 	ctx := r.Context()
 	val := ctx.Value(ContextValidatorKey)
@@ -97,10 +127,15 @@ func ParseParameters(r *http.Request, dst interface{}) error {
 	return nil
 }
 
-// SendResponse encodes data as JSON object and returns it to client
+// SendResponse encodes data in the content type negotiated by NegotiationMiddleware,
+// JSON by default, and returns it to client
 func SendResponse(w http.ResponseWriter, statusCode int, code ErrorCode,
 	message string, data map[string]interface{}) error {
-	w.Header().Set(HeaderContentType, ContentTypeJSON)
+	contentType, negotiated := negotiatedContentType(w)
+	if negotiated {
+		w.Header().Add(HeaderVary, HeaderAccept)
+	}
+	w.Header().Set(HeaderContentType, contentType)
 	w.WriteHeader(int(statusCode))
 	ti := lib.TimeRFC3339(time.Now())
 	respData := ServerResponseData{}
@@ -120,7 +155,7 @@ func SendResponse(w http.ResponseWriter, statusCode int, code ErrorCode,
 		Data:         respData,
 		Time:         &ti,
 	}
-	body, err := json.Marshal(obj)
+	body, err := encoderOf(contentType)(obj)
 	if err != nil {
 		return errors.Wrap(err, lib.StringTags("send response", "marshal body"))
 	}
@@ -129,22 +164,16 @@ func SendResponse(w http.ResponseWriter, statusCode int, code ErrorCode,
 	return err
 }
 
-// SendError send internal server error
+// SendError sends the status and error code declared by a StatusError in the error
+// chain, other errors are sent as internal server error. Details of a DetailedError
+// are sent as error data
 func SendError(w http.ResponseWriter, err error) error {
-	var status int
-	var errorCode ErrorCode
-	switch err.(type) {
-	case BadRequestError:
-		status = http.StatusBadRequest
-		errorCode = ErrorCodeBadRequest
-	case ValidationError:
-		status = http.StatusBadRequest
-		errorCode = ErrorCodeValidationFailed
-	default:
-		status = http.StatusInternalServerError
-		errorCode = ErrorCodeInternalError
+	status, errorCode := statusOf(err)
+	var data map[string]interface{}
+	if details := detailsOf(err); details != nil {
+		data = map[string]interface{}{"error": details}
 	}
-	return SendResponse(w, status, errorCode, err.Error(), nil)
+	return SendResponse(w, status, errorCode, err.Error(), data)
 }
 
 func buildRouteHandler(method string, validators []ParamValidator, handle http.HandlerFunc) http.HandlerFunc {
@@ -154,6 +183,11 @@ func buildRouteHandler(method string, validators []ParamValidator, handle http.H
 		if len(validators) > 0 {
 			ctx = context.WithValue(ctx, ContextValidatorKey, validators)
 		}
-		handle(w, r.WithContext(ctx))
+		r = r.WithContext(ctx)
+		handle(w, r)
+		// the server only cleans up multipart form of the request it created
+		if r.MultipartForm != nil {
+			r.MultipartForm.RemoveAll()
+		}
 	}
 }
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// messageSuccess is the response message of handlers without error
+const messageSuccess = "success"
+
+// HandleFunc handles the decoded request and returns response data
+type HandleFunc func(ctx context.Context, req interface{}) (interface{}, error)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Handle creates handler decoding a request created by newRequest with
+// ParseParameters, the route validators included, then calling handle. The result
+// is sent as success data of ServerResponse and errors are sent by SendError
+func Handle(newRequest func() interface{}, handle HandleFunc) http.HandlerFunc {
+	return HandleMessage(newRequest, messageSuccess, handle)
+}
+
+// HandleMessage is like Handle but replies message on success
+func HandleMessage(newRequest func() interface{}, message string, handle HandleFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := newRequest()
+		if err := ParseParameters(r, req); err != nil {
+			SendError(w, err)
+			return
+		}
+		resp, err := handle(r.Context(), req)
+		if err != nil {
+			SendError(w, err)
+			return
+		}
+		var data map[string]interface{}
+		if resp != nil {
+			data = map[string]interface{}{"success": resp}
+		}
+		SendResponse(w, http.StatusOK, ErrorCodeSuccess, message, data)
+	}
+}
+
+// TypedHandler adapts fn of form func(context.Context, *Req) (Resp, error) to a
+// handler, see Handle
+func TypedHandler(fn interface{}) (http.HandlerFunc, error) {
+	fv, ft := reflect.ValueOf(fn), reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func || ft.NumIn() != 2 || ft.NumOut() != 2 ||
+		ft.In(0) != contextType || ft.In(1).Kind() != reflect.Ptr || ft.Out(1) != errorType {
+		return nil, errors.Errorf("typed handler: %s is not func(context.Context, *Req) (Resp, error)", ft)
+	}
+	reqType := ft.In(1).Elem()
+	return Handle(func() interface{} {
+		return reflect.New(reqType).Interface()
+	}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		out := fv.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		switch out[0].Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			if out[0].IsNil() {
+				return nil, nil
+			}
+		}
+		return out[0].Interface(), nil
+	}), nil
+}
+
+// MustTypedHandler is like TypedHandler but panics on invalid fn, it simplifies
+// declaring routes
+func MustTypedHandler(fn interface{}) http.HandlerFunc {
+	handler, err := TypedHandler(fn)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
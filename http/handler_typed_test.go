@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type typedRequest struct {
+	Name string `json:"name" schema:"name"`
+}
+
+type typedResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	t.Parallel()
+	for _, fn := range []interface{}{
+		nil,
+		"handler",
+		func(context.Context, typedRequest) (*typedResponse, error) { return nil, nil },
+		func(*typedRequest) (*typedResponse, error) { return nil, nil },
+		func(context.Context, *typedRequest) *typedResponse { return nil },
+		func(context.Context, *typedRequest) (*typedResponse, string) { return nil, "" },
+	} {
+		_, err := TypedHandler(fn)
+		require.Error(t, err)
+	}
+	require.Panics(t, func() { MustTypedHandler(nil) })
+
+	handler := MustTypedHandler(func(ctx context.Context, req *typedRequest) (*typedResponse, error) {
+		switch req.Name {
+		case "":
+			return nil, nil
+		case "missing":
+			return nil, Error{http.StatusNotFound, ErrorCodeFailed, errors.New("not found")}
+		case "admin":
+			return nil, errors.Wrap(ForbiddenError{errors.New("forbidden")}, "greet")
+		case "gone":
+			return nil, Error{StatusCode: http.StatusGone, Code: ErrorCodeFailed}
+		case "invalid status":
+			return nil, Error{0, ErrorCodeFailed, errors.New("no status")}
+		case "boom":
+			return nil, errors.New("boom")
+		}
+		return &typedResponse{Greeting: "hello " + req.Name}, nil
+	})
+	validators := []ParamValidator{func(_ context.Context, req interface{}) error {
+		if req.(*typedRequest).Name == "invalid" {
+			return errors.New("invalid name")
+		}
+		return nil
+	}}
+	routeHandler := buildRouteHandler(http.MethodPost, validators, handler)
+	testCases := []struct {
+		Name      string
+		Body      string
+		Status    int
+		ErrorCode ErrorCode
+		Message   string
+		Success   interface{}
+	}{
+		{"success", `{"name":"gom"}`, http.StatusOK, ErrorCodeSuccess, messageSuccess,
+			map[string]interface{}{"greeting": "hello gom"}},
+		{"nil response", `{}`, http.StatusOK, ErrorCodeSuccess, messageSuccess, nil},
+		{"malformed", `{`, http.StatusBadRequest, ErrorCodeBadRequest, "unexpected EOF", nil},
+		{"validator", `{"name":"invalid"}`, http.StatusBadRequest, ErrorCodeValidationFailed,
+			"invalid name", nil},
+		{"status error", `{"name":"missing"}`, http.StatusNotFound, ErrorCodeFailed, "not found", nil},
+		{"status error without cause", `{"name":"gone"}`, http.StatusGone, ErrorCodeFailed,
+			"Gone", nil},
+		{"invalid status", `{"name":"invalid status"}`, http.StatusInternalServerError,
+			ErrorCodeInternalError, "no status", nil},
+		{"wrapped status error", `{"name":"admin"}`, http.StatusForbidden, ErrorCodeForbidden,
+			"greet: forbidden", nil},
+		{"internal error", `{"name":"boom"}`, http.StatusInternalServerError,
+			ErrorCodeInternalError, "boom", nil},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.Body))
+		r.Header.Set(HeaderContentType, ContentTypeJSON)
+		w := httptest.NewRecorder()
+		routeHandler(w, r)
+		require.Equal(t, tc.Status, w.Code, tc.Name)
+		dest := ServerResponse{}
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest), tc.Name)
+		require.Equal(t, tc.ErrorCode, dest.ErrorCode, tc.Name)
+		require.Equal(t, tc.Message, dest.ErrorMessage, tc.Name)
+		require.Equal(t, tc.Success, dest.Data.Success, tc.Name)
+	}
+}
+
+func TestHandleMessage(t *testing.T) {
+	t.Parallel()
+	handler := HandleMessage(func() interface{} { return &typedRequest{} }, "created successfully",
+		func(_ context.Context, req interface{}) (interface{}, error) {
+			return req, nil
+		})
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"gom"}`))
+	r.Header.Set(HeaderContentType, ContentTypeJSON)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	dest := ServerResponse{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest))
+	require.Equal(t, "created successfully", dest.ErrorMessage)
+	require.Equal(t, map[string]interface{}{"name": "gom"}, dest.Data.Success)
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	sdk "github.com/hauxe/gom"
+	"github.com/pkg/errors"
+)
+
+// health endpoints
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// health status
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// healthCheckTimeout bounds each dependency check
+const healthCheckTimeout = 5 * time.Second
+
+// HealthCheckerFunc adapts a function to the health checker interface
+type HealthCheckerFunc func(ctx context.Context) error
+
+// HealthCheck runs the check function
+func (f HealthCheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+// DependencyHealth defines health check result of a dependency
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport defines health endpoint response data
+type HealthReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies,omitempty"`
+}
+
+// SetHealthCheckOption registers liveness and readiness routes, liveness checkers
+// should only cover failures which need a restart, readiness also fails while the
+// server is starting or stopping
+func (s *Server) SetHealthCheckOption(liveness, readiness map[string]sdk.HealthChecker) StartServerOptions {
+	return s.SetHandlerOption(
+		ServerRoute{
+			Name:    "health_liveness",
+			Method:  http.MethodGet,
+			Path:    LivenessPath,
+			Handler: s.healthHandler(liveness, false),
+		},
+		ServerRoute{
+			Name:    "health_readiness",
+			Method:  http.MethodGet,
+			Path:    ReadinessPath,
+			Handler: s.healthHandler(readiness, true),
+		},
+	)
+}
+
+func (s *Server) healthHandler(checkers map[string]sdk.HealthChecker, readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := CheckHealth(r.Context(), checkers)
+		status, code, message, key := http.StatusOK, ErrorCodeSuccess, "healthy", "success"
+		switch {
+		case readiness && !s.Ready():
+			report.Status = HealthStatusDown
+			status, code, message, key = http.StatusServiceUnavailable,
+				ErrorCodeServiceUnavailable, "server is not ready", "error"
+		case report.Status != HealthStatusUp:
+			status, code, message, key = http.StatusServiceUnavailable,
+				ErrorCodeServiceUnavailable, "dependency is unhealthy", "error"
+		}
+		err := SendResponse(w, status, code, message, map[string]interface{}{
+			key: report,
+		})
+		if err != nil {
+			s.Logger.For(r.Context()).Error(err.Error())
+		}
+	}
+}
+
+// CheckHealth runs all checkers concurrently and reports their status and latency
+func CheckHealth(ctx context.Context, checkers map[string]sdk.HealthChecker) HealthReport {
+	report := HealthReport{
+		Status:       HealthStatusUp,
+		Dependencies: make(map[string]DependencyHealth, len(checkers)),
+	}
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker sdk.HealthChecker) {
+			defer wg.Done()
+			health := checkDependency(ctx, checker)
+			mux.Lock()
+			defer mux.Unlock()
+			report.Dependencies[name] = health
+			if health.Status != HealthStatusUp {
+				report.Status = HealthStatusDown
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+	return report
+}
+
+func checkDependency(ctx context.Context, checker sdk.HealthChecker) (health DependencyHealth) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	err := errors.New("checker is nil")
+	if checker != nil {
+		err = checker.HealthCheck(ctx)
+	}
+	health.LatencyMS = float64(time.Since(start)) / float64(time.Millisecond)
+	health.Status = HealthStatusUp
+	if err != nil {
+		health.Status = HealthStatusDown
+		health.Error = err.Error()
+	}
+	return health
+}
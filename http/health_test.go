@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sdk "github.com/hauxe/gom"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type healthResponse struct {
+	ErrorCode ErrorCode `json:"error_code"`
+	Data      struct {
+		Success HealthReport `json:"success"`
+		Error   HealthReport `json:"error"`
+	} `json:"data"`
+}
+
+func TestCheckHealth(t *testing.T) {
+	t.Parallel()
+	t.Run("empty checkers", func(t *testing.T) {
+		t.Parallel()
+		report := CheckHealth(context.Background(), nil)
+		require.Equal(t, HealthStatusUp, report.Status)
+		require.Empty(t, report.Dependencies)
+	})
+
+	t.Run("one dependency down", func(t *testing.T) {
+		t.Parallel()
+		report := CheckHealth(context.Background(), map[string]sdk.HealthChecker{
+			"db": HealthCheckerFunc(func(_ context.Context) error {
+				return nil
+			}),
+			"cache": HealthCheckerFunc(func(_ context.Context) error {
+				return errors.New("connection refused")
+			}),
+			"nil": nil,
+		})
+		require.Equal(t, HealthStatusDown, report.Status)
+		require.Equal(t, HealthStatusUp, report.Dependencies["db"].Status)
+		require.Empty(t, report.Dependencies["db"].Error)
+		require.Equal(t, HealthStatusDown, report.Dependencies["cache"].Status)
+		require.Equal(t, "connection refused", report.Dependencies["cache"].Error)
+		require.Equal(t, HealthStatusDown, report.Dependencies["nil"].Status)
+	})
+
+	t.Run("checker respects timeout context", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report := CheckHealth(ctx, map[string]sdk.HealthChecker{
+			"slow": HealthCheckerFunc(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}),
+		})
+		require.Equal(t, HealthStatusDown, report.Status)
+	})
+}
+
+func TestServerHealthCheck(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	var cacheErr error
+	err = server.Start(server.SetHostPortOption("localhost", 18006),
+		server.SetHealthCheckOption(nil, map[string]sdk.HealthChecker{
+			"cache": HealthCheckerFunc(func(_ context.Context) error {
+				return cacheErr
+			}),
+		}))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+	get := func(path string) (*http.Response, healthResponse) {
+		resp, err := hc.Get(server.URL + path)
+		require.Nil(t, err)
+		dest := healthResponse{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+		return resp, dest
+	}
+
+	resp, dest := get(LivenessPath)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, ErrorCodeSuccess, dest.ErrorCode)
+	require.Equal(t, HealthStatusUp, dest.Data.Success.Status)
+
+	resp, dest = get(ReadinessPath)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, HealthStatusUp, dest.Data.Success.Dependencies["cache"].Status)
+
+	cacheErr = errors.New("cache is down")
+	resp, dest = get(ReadinessPath)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, ErrorCodeServiceUnavailable, dest.ErrorCode)
+	require.Equal(t, HealthStatusDown, dest.Data.Error.Status)
+	require.Equal(t, "cache is down", dest.Data.Error.Dependencies["cache"].Error)
+
+	// liveness does not depend on readiness checkers
+	resp, _ = get(LivenessPath)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
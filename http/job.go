@@ -13,7 +13,7 @@ type JobHandler struct {
 	w       http.ResponseWriter
 	r       *http.Request
 	handler http.HandlerFunc
-	c       chan struct{}
+	c       chan interface{} // receives the recovered handler panic, nil when it returns
 }
 
 // Name get job name
@@ -26,12 +26,20 @@ func (job *JobHandler) GetContext() context.Context {
 	return job.r.Context()
 }
 
-// Execute handle job
-func (job *JobHandler) Execute() error {
+// Execute handle job, a handler panic is passed to the waiting request or returned
+// so it does not crash the worker
+func (job *JobHandler) Execute() (err error) {
 	if job.handler == nil {
 		return errors.New("empty handler")
 	}
+	defer func() {
+		recovered := recover()
+		if job.c != nil {
+			job.c <- recovered
+		} else if recovered != nil {
+			err = errors.Errorf("handler panic: %v", recovered)
+		}
+	}()
 	job.handler(job.w, job.r)
-	job.c <- struct{}{}
 	return nil
 }
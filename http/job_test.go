@@ -38,7 +38,7 @@ func TestExecute(t *testing.T) {
 	t.Run("error empty handler", func(t *testing.T) {
 		t.Parallel()
 		job := JobHandler{
-			c: make(chan struct{}, 1),
+			c: make(chan interface{}, 1),
 		}
 		require.Error(t, job.Execute())
 	})
@@ -48,7 +48,22 @@ func TestExecute(t *testing.T) {
 		f := func(_ http.ResponseWriter, _ *http.Request) {
 
 		}
-		job := JobHandler{handler: f, c: make(chan struct{}, 1)}
+		job := JobHandler{handler: f, c: make(chan interface{}, 1)}
 		require.Nil(t, job.Execute())
+		require.Nil(t, <-job.c)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		t.Parallel()
+		f := func(_ http.ResponseWriter, _ *http.Request) {
+			panic("boom")
+		}
+		job := JobHandler{handler: f, c: make(chan interface{}, 1)}
+		require.Nil(t, job.Execute())
+		require.Equal(t, "boom", <-job.c)
+
+		// async jobs have no waiting request
+		job = JobHandler{handler: f}
+		require.EqualError(t, job.Execute(), "handler panic: boom")
 	})
 }
@@ -0,0 +1,304 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+)
+
+// listener networks
+const (
+	NetworkTCP  = "tcp"
+	NetworkUnix = "unix"
+)
+
+// ListenerConfig defines an address the server listens on
+type ListenerConfig struct {
+	// Network is NetworkTCP by default or NetworkUnix
+	Network string
+	// Address is host:port or the unix socket path
+	Address string
+	// TLS serves HTTPS with the server certificate, HTTP/2 is negotiated by ALPN
+	TLS bool
+	// H2C serves HTTP/2 without TLS to clients with prior knowledge next to HTTP/1
+	H2C bool
+	// Redirect redirects every request to the first TLS listener
+	Redirect bool
+}
+
+// SetListenersOption set http server listens on listeners instead of host and port,
+// the first one is the server URL
+func (s *Server) SetListenersOption(listeners ...ListenerConfig) StartServerOptions {
+	return func() (err error) {
+		for _, listener := range listeners {
+			if listener.Address == "" {
+				return errors.New(lib.StringTags("set listeners", "address is required"))
+			}
+			if listener.Network == "" {
+				listener.Network = NetworkTCP
+			}
+			if listener.Network != NetworkTCP && listener.Network != NetworkUnix {
+				return errors.New(lib.StringTags("set listeners", "unsupported network", listener.Network))
+			}
+			s.listeners = append(s.listeners, listener)
+		}
+		return nil
+	}
+}
+
+// listen binds every listener, either all are bound or none
+func (s *Server) listen(listeners []ListenerConfig) (bound []net.Listener, err error) {
+	defer func() {
+		if err != nil {
+			for _, l := range bound {
+				l.Close()
+			}
+		}
+	}()
+	for _, listener := range listeners {
+		if listener.Network == NetworkUnix {
+			removeStaleSocket(listener.Address)
+		}
+		l, err := net.Listen(listener.Network, listener.Address)
+		if err != nil {
+			return bound, errors.Wrap(err, lib.StringTags("listen", listener.Network, listener.Address))
+		}
+		bound = append(bound, l)
+	}
+	return bound, nil
+}
+
+// serve starts serving bound listeners in background
+func (s *Server) serve(listeners []ListenerConfig, bound []net.Listener) error {
+	var httpsPort string
+	var h2 *http2.Server
+	for i, listener := range listeners {
+		if listener.TLS && httpsPort == "" && listener.Network == NetworkTCP {
+			_, httpsPort, _ = net.SplitHostPort(bound[i].Addr().String())
+		}
+		if listener.H2C && h2 == nil {
+			// configured before serving, it also registers graceful shutdown of
+			// http2 connections
+			h2 = &http2.Server{}
+			if err := http2.ConfigureServer(s.S, h2); err != nil {
+				return errors.Wrap(err, lib.StringTags("serve", "configure http2"))
+			}
+		}
+	}
+	for _, listener := range listeners {
+		if listener.Redirect && httpsPort == "" {
+			return errors.New(lib.StringTags("serve", "redirect requires a tcp tls listener"))
+		}
+	}
+	for i, listener := range listeners {
+		l := bound[i]
+		var serve func() error
+		switch {
+		case listener.Redirect:
+			server := &http.Server{
+				Handler:      redirectHandler(httpsPort),
+				ReadTimeout:  s.S.ReadTimeout,
+				WriteTimeout: s.S.WriteTimeout,
+			}
+			s.servers = append(s.servers, server)
+			serve = func() error { return server.Serve(l) }
+		case listener.TLS:
+			serve = func() error { return s.S.ServeTLS(l, "", "") }
+		case listener.H2C:
+			h2cl := newH2CListener(l, s.S, h2)
+			serve = func() error { return s.S.Serve(h2cl) }
+		default:
+			serve = func() error { return s.S.Serve(l) }
+		}
+		s.Logger.Bg().Info("Starting HTTP server", zap.String("network", listener.Network),
+			zap.String("address", l.Addr().String()), zap.Bool("tls", listener.TLS),
+			zap.Bool("h2c", listener.H2C), zap.Bool("redirect", listener.Redirect))
+		go func(listener ListenerConfig) {
+			if err := serve(); err != nil && err != http.ErrServerClosed {
+				s.Logger.Bg().Error("serve HTTP server", zap.String("address", listener.Address),
+					zap.Error(err))
+			}
+		}(listener)
+	}
+	return nil
+}
+
+// loadTLSConfig loads the server certificate so invalid files fail on start, verified
+// client identity is put in request context
+func (s *Server) loadTLSConfig() (err error) {
+	config := s.Config.TLSConfig()
+	if s.tlsConfig != nil {
+		copied := *s.tlsConfig
+		config = &copied
+	}
+	if config.OnReloadError == nil {
+		config.OnReloadError = func(err error) {
+			s.Logger.Bg().Error("reload certificate", zap.Error(err))
+		}
+	}
+	if s.S.TLSConfig, s.certReloader, err = lib.NewServerTLSConfig(config); err != nil {
+		return err
+	}
+	handler := s.S.Handler
+	s.S.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity := lib.ClientIdentityOf(r.TLS); identity != nil {
+			r = r.WithContext(lib.ContextWithClientIdentity(r.Context(), identity))
+		}
+		handler.ServeHTTP(w, r)
+	})
+	return nil
+}
+
+// urlOf returns URL of the listener, unix sockets have the socket path as host
+func urlOf(listener ListenerConfig, l net.Listener) string {
+	if listener.Network == NetworkUnix {
+		return "unix://" + listener.Address
+	}
+	scheme := "http"
+	if listener.TLS {
+		scheme = "https"
+	}
+	address := listener.Address
+	if _, port, err := net.SplitHostPort(address); err == nil && port == "0" {
+		// use the port chosen by the system
+		address = l.Addr().String()
+	}
+	return fmt.Sprintf("%s://%s", scheme, address)
+}
+
+// removeStaleSocket removes socket file left by a server which did not stop
+func removeStaleSocket(path string) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial(NetworkUnix, path); err == nil {
+			// socket is in use, listening reports it
+			conn.Close()
+			return
+		}
+		os.Remove(path)
+	}
+}
+
+// redirectHandler redirects requests to the same host and URI on https port
+func redirectHandler(httpsPort string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	}
+}
+
+// h2cListener serves connections starting with the HTTP/2 client preface by the
+// HTTP/2 server and accepts the others as HTTP/1 connections
+type h2cListener struct {
+	net.Listener
+	server    *http.Server
+	h2        *http2.Server
+	conns     chan net.Conn
+	errc      chan error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newH2CListener(l net.Listener, server *http.Server, h2 *http2.Server) *h2cListener {
+	h2cl := &h2cListener{
+		Listener: l,
+		server:   server,
+		h2:       h2,
+		conns:    make(chan net.Conn),
+		errc:     make(chan error, 1),
+		closed:   make(chan struct{}),
+	}
+	go h2cl.acceptLoop()
+	return h2cl
+}
+
+func (l *h2cListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			l.errc <- err
+			return
+		}
+		go l.dispatch(conn)
+	}
+}
+
+// dispatch reads the beginning of conn until it differs from the preface
+func (l *h2cListener) dispatch(conn net.Conn) {
+	if l.server.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(l.server.ReadTimeout))
+	}
+	reader := bufio.NewReader(conn)
+	isH2 := true
+	for i := 1; i <= len(http2.ClientPreface) && isH2; i++ {
+		b, err := reader.Peek(i)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		isH2 = b[i-1] == http2.ClientPreface[i-1]
+	}
+	conn.SetReadDeadline(time.Time{})
+	peeked := &peekedConn{Conn: conn, reader: reader}
+	if isH2 {
+		l.h2.ServeConn(peeked, &http2.ServeConnOpts{BaseConfig: l.server, Handler: l.server.Handler})
+		return
+	}
+	select {
+	case l.conns <- peeked:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// Accept returns the next HTTP/1 connection
+func (l *h2cListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errc:
+		return nil, err
+	case <-l.closed:
+		return nil, errors.New(lib.StringTags("accept", "listener is closed"))
+	}
+}
+
+// Close stops accepting connections
+func (l *h2cListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return l.Listener.Close()
+}
+
+// peekedConn reads the bytes peeked while dispatching first
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
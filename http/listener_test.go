@@ -0,0 +1,288 @@
+package http
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// writeCertificate writes a certificate for localhost signed by parent, nil parent
+// creates a self signed CA
+func writeCertificate(t *testing.T, dir, name string, parent *tls.Certificate,
+	usage x509.ExtKeyUsage) (certFile, keyFile string, cert *tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.Nil(t, ioutil.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.Nil(t, ioutil.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	leaf, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return certFile, keyFile, &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
+
+func TestStartBindError(t *testing.T) {
+	t.Parallel()
+	first, err := CreateServer()
+	require.Nil(t, err)
+	require.Nil(t, first.Start(first.SetHostPortOption("localhost", 18014)))
+	defer first.Stop()
+	second, err := CreateServer()
+	require.Nil(t, err)
+	err = second.Start(second.SetHostPortOption("localhost", 18014))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "address already in use")
+
+	dir, err := ioutil.TempDir("", "listener")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetTLSOption(true, filepath.Join(dir, "missing.crt"),
+		filepath.Join(dir, "missing.key")))
+	require.NotNil(t, err)
+	err = server.Start(server.SetListenersOption(
+		ListenerConfig{Address: "127.0.0.1:0", Redirect: true}))
+	require.NotNil(t, err)
+}
+
+func TestListeners(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "listener")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile, cert := writeCertificate(t, dir, "server", nil, x509.ExtKeyUsageServerAuth)
+	socket := filepath.Join(dir, "server.sock")
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetTLSOption(false, certFile, keyFile),
+		server.SetListenersOption(
+			ListenerConfig{Address: "127.0.0.1:0", H2C: true},
+			ListenerConfig{Network: NetworkUnix, Address: socket},
+			ListenerConfig{Address: "127.0.0.1:0", TLS: true},
+			ListenerConfig{Address: "127.0.0.1:0", Redirect: true},
+		),
+		server.SetHandlerOption(ServerRoute{Name: "proto", Method: http.MethodGet, Path: "/proto",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, r.Proto, nil)
+			}}))
+	require.Nil(t, err)
+	defer server.Stop()
+	addrs := server.Addrs()
+	require.Len(t, addrs, 4)
+	require.Equal(t, "http://"+addrs[0].String(), server.URL)
+	proto := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		dest := ServerResponse{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+		return dest.ErrorMessage
+	}
+
+	// h2c listener serves HTTP/1 and HTTP/2 with prior knowledge
+	require.Equal(t, "HTTP/1.1", proto(http.DefaultClient, server.URL+"/proto"))
+	h2c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	require.Equal(t, "HTTP/2.0", proto(h2c, server.URL+"/proto"))
+
+	unix := &http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) {
+			return net.Dial(NetworkUnix, socket)
+		},
+	}}
+	require.Equal(t, "HTTP/1.1", proto(unix, "http://unix/proto"))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	https := &http.Client{Transport: &http2.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	require.Equal(t, "HTTP/2.0", proto(https, "https://"+addrs[2].String()+"/proto"))
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noFollow.Get("http://" + addrs[3].String() + "/proto?q=1")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	require.Equal(t, "https://"+addrs[2].String()+"/proto?q=1", resp.Header.Get("Location"))
+
+	require.Nil(t, server.Stop())
+	_, err = os.Stat(socket)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestUnixConnWriteTimeout(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "unix")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "gom.sock")
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetTimeoutOption(32, 1),
+		server.SetListenersOption(ListenerConfig{Network: NetworkUnix, Address: socket}),
+		server.SetHandlerOption(ServerRoute{
+			Name:         "slow",
+			Method:       http.MethodGet,
+			Path:         "/slow",
+			WriteTimeout: -1,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				// written after the server write timeout expired
+				time.Sleep(1500 * time.Millisecond)
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, "ok", nil)
+			}}))
+	require.Nil(t, err)
+	defer server.Stop()
+
+	// both connections are open before either request overrides its deadline
+	conns := make([]net.Conn, 2)
+	for i := range conns {
+		conns[i], err = net.Dial(NetworkUnix, socket)
+		require.Nil(t, err)
+		defer conns[i].Close()
+	}
+	time.Sleep(50 * time.Millisecond)
+	messages := make(chan string, len(conns))
+	for _, conn := range conns {
+		go func(conn net.Conn) {
+			_, err := conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: unix\r\n\r\n"))
+			if err != nil {
+				messages <- err.Error()
+				return
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				messages <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			dest := ServerResponse{}
+			if err = json.NewDecoder(resp.Body).Decode(&dest); err != nil {
+				messages <- err.Error()
+				return
+			}
+			messages <- dest.ErrorMessage
+		}(conn)
+	}
+	require.Equal(t, "ok", <-messages)
+	require.Equal(t, "ok", <-messages)
+}
+
+func TestMutualTLS(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "mtls")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile, _, ca := writeCertificate(t, dir, "ca", nil, x509.ExtKeyUsageAny)
+	certFile, keyFile, _ := writeCertificate(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	_, _, alice := writeCertificate(t, dir, "alice", ca, x509.ExtKeyUsageClientAuth)
+	_, _, mallory := writeCertificate(t, dir, "mallory", ca, x509.ExtKeyUsageClientAuth)
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetTLSConfigOption(&lib.TLSConfig{
+		CertFile:        certFile,
+		KeyFile:         keyFile,
+		ReloadInterval:  20 * time.Millisecond,
+		ClientCAFile:    caFile,
+		ClientAuth:      lib.ClientAuthRequire,
+		AllowedSubjects: []string{"alice"},
+	}), server.SetListenersOption(ListenerConfig{Address: "127.0.0.1:0", TLS: true}),
+		server.SetHandlerOption(ServerRoute{Name: "whoami", Method: http.MethodGet, Path: "/whoami",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				identity := lib.ClientIdentityFromContext(r.Context())
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, identity.CommonName, nil)
+			}}))
+	require.Nil(t, err)
+	defer server.Stop()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	get := func(cert *tls.Certificate) (string, string, error) {
+		config := &tls.Config{RootCAs: pool}
+		if cert != nil {
+			config.Certificates = []tls.Certificate{*cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, err := client.Get(server.URL + "/whoami")
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+		dest := ServerResponse{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+		return dest.ErrorMessage, resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	name, serverName, err := get(alice)
+	require.Nil(t, err)
+	require.Equal(t, "alice", name)
+	require.Equal(t, "server", serverName)
+	_, _, err = get(mallory)
+	require.NotNil(t, err)
+	_, _, err = get(nil)
+	require.NotNil(t, err)
+
+	// replaced certificate is served without restart
+	renewedCert, renewedKey, _ := writeCertificate(t, dir, "renewed", ca, x509.ExtKeyUsageServerAuth)
+	future := time.Now().Add(time.Minute)
+	require.Nil(t, os.Rename(renewedCert, certFile))
+	require.Nil(t, os.Rename(renewedKey, keyFile))
+	require.Nil(t, os.Chtimes(certFile, future, future))
+	require.Nil(t, os.Chtimes(keyFile, future, future))
+	for i := 0; i < 100 && serverName != "renewed"; i++ {
+		time.Sleep(20 * time.Millisecond)
+		_, serverName, err = get(alice)
+		require.Nil(t, err)
+	}
+	require.Equal(t, "renewed", serverName)
+}
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hauxe/gom/pool"
+)
+
+const (
+	// MetricsPath is the default metrics endpoint
+	MetricsPath = "/metrics"
+	// metricsContentType is the prometheus text exposition format
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// unmatchedRoute labels requests which match no route
+	unmatchedRoute = "unmatched"
+	// otherMethod labels non standard methods to bound label cardinality
+	otherMethod = "OTHER"
+)
+
+// default histogram buckets
+var (
+	DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	DefaultSizeBuckets     = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+type metricLabels struct {
+	route  string
+	method string
+	status string
+}
+
+func (l metricLabels) String() string {
+	return fmt.Sprintf(`route="%s",method="%s",status="%s"`,
+		escapeLabelValue(l.route), escapeLabelValue(l.method), escapeLabelValue(l.status))
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Metrics collects http server request metrics and exposes them in prometheus
+// text format
+type Metrics struct {
+	DurationBuckets []float64
+	SizeBuckets     []float64
+	// WorkerPools reports worker pools whose active jobs are exposed
+	WorkerPools func() []*pool.Worker
+	inFlight    int64
+	requests    map[metricLabels]uint64
+	durations   map[metricLabels]*histogram
+	sizes       map[metricLabels]*histogram
+	mux         sync.Mutex
+}
+
+// NewMetrics creates metrics collector with default buckets
+func NewMetrics() *Metrics {
+	return &Metrics{
+		DurationBuckets: DefaultDurationBuckets,
+		SizeBuckets:     DefaultSizeBuckets,
+		requests:        make(map[metricLabels]uint64),
+		durations:       make(map[metricLabels]*histogram),
+		sizes:           make(map[metricLabels]*histogram),
+	}
+}
+
+// SetMetricsOption records request metrics of every route and exposes them on
+// path, empty path uses MetricsPath
+func (s *Server) SetMetricsOption(path string) StartServerOptions {
+	return func() (err error) {
+		if path == "" {
+			path = MetricsPath
+		}
+		metrics := NewMetrics()
+		metrics.WorkerPools = func() []*pool.Worker {
+			return s.WorkerPools
+		}
+		s.Metrics = metrics
+		s.Use(metrics.Middleware)
+		return s.SetHandlerOption(ServerRoute{
+			Name:    "metrics",
+			Method:  http.MethodGet,
+			Path:    path,
+			Handler: metrics.ServeHTTP,
+		})()
+	}
+}
+
+// Middleware records request count, latency, response size and in-flight requests
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+		start := time.Now()
+		r, info := withRouteInfo(r)
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+		route := info.name
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.observe(metricLabels{
+			route:  route,
+			method: metricMethod(r.Method),
+			status: strconv.Itoa(rw.Status()),
+		}, time.Since(start), rw.Size())
+	})
+}
+
+func (m *Metrics) observe(labels metricLabels, duration time.Duration, size int64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.requests[labels]++
+	if _, existed := m.durations[labels]; !existed {
+		m.durations[labels] = newHistogram(m.DurationBuckets)
+		m.sizes[labels] = newHistogram(m.SizeBuckets)
+	}
+	m.durations[labels].observe(duration.Seconds())
+	m.sizes[labels].observe(float64(size))
+}
+
+// ServeHTTP writes metrics in prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(HeaderContentType, metricsContentType)
+	w.Write(m.Expose())
+}
+
+// Expose returns metrics in prometheus text exposition format
+func (m *Metrics) Expose() []byte {
+	var buf bytes.Buffer
+	m.mux.Lock()
+	labels := make([]metricLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].String() < labels[j].String()
+	})
+	writeMetricHeader(&buf, "http_server_requests_total", "counter",
+		"Total number of HTTP requests.")
+	for _, l := range labels {
+		fmt.Fprintf(&buf, "http_server_requests_total{%s} %d\n", l, m.requests[l])
+	}
+	writeMetricHeader(&buf, "http_server_request_duration_seconds", "histogram",
+		"HTTP request latency in seconds.")
+	for _, l := range labels {
+		writeHistogram(&buf, "http_server_request_duration_seconds", l, m.durations[l])
+	}
+	writeMetricHeader(&buf, "http_server_response_size_bytes", "histogram",
+		"HTTP response body size in bytes.")
+	for _, l := range labels {
+		writeHistogram(&buf, "http_server_response_size_bytes", l, m.sizes[l])
+	}
+	m.mux.Unlock()
+
+	writeMetricHeader(&buf, "http_server_requests_in_flight", "gauge",
+		"Number of HTTP requests being served.")
+	fmt.Fprintf(&buf, "http_server_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight))
+	if m.WorkerPools == nil {
+		return buf.Bytes()
+	}
+	workerPools := m.WorkerPools()
+	if len(workerPools) == 0 {
+		return buf.Bytes()
+	}
+	writeMetricHeader(&buf, "http_server_worker_pool_active_jobs", "gauge",
+		"Number of jobs being executed by the worker pool.")
+	for i, workerPool := range workerPools {
+		fmt.Fprintf(&buf, "http_server_worker_pool_active_jobs{pool=\"%d\"} %d\n",
+			i, workerPool.ActiveJobs())
+	}
+	writeMetricHeader(&buf, "http_server_worker_pool_max_workers", "gauge",
+		"Number of workers in the worker pool.")
+	for i, workerPool := range workerPools {
+		maxWorkers := 0
+		if workerPool.Config != nil {
+			maxWorkers = workerPool.Config.MaxWorkers
+		}
+		fmt.Fprintf(&buf, "http_server_worker_pool_max_workers{pool=\"%d\"} %d\n",
+			i, maxWorkers)
+	}
+	return buf.Bytes()
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeHistogram(buf *bytes.Buffer, name string, l fmt.Stringer, h *histogram) {
+	for i, bound := range h.buckets {
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, l, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, l, h.count)
+	fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, l, formatFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count{%s} %d\n", name, l, h.count)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func metricMethod(method string) string {
+	for _, m := range httpMethods {
+		if m == method {
+			return method
+		}
+	}
+	if method == http.MethodHead {
+		return method
+	}
+	return otherMethod
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	t.Parallel()
+	metrics := NewMetrics()
+	metrics.DurationBuckets = []float64{0.5, 1}
+	metrics.SizeBuckets = []float64{1, 10}
+	handler := metrics.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, info := withRouteInfo(r)
+		info.name = `get "user"`
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/", nil))
+
+	exposed := string(metrics.Expose())
+	labels := `route="get \"user\"",method="GET",status="201"`
+	for _, line := range []string{
+		"# TYPE http_server_requests_total counter",
+		"http_server_requests_total{" + labels + "} 1",
+		`http_server_requests_total{route="get \"user\"",method="OTHER",status="201"} 1`,
+		"# TYPE http_server_request_duration_seconds histogram",
+		"http_server_request_duration_seconds_bucket{" + labels + `,le="0.5"} 1`,
+		"http_server_request_duration_seconds_bucket{" + labels + `,le="+Inf"} 1`,
+		"http_server_request_duration_seconds_count{" + labels + "} 1",
+		"http_server_response_size_bytes_bucket{" + labels + `,le="1"} 0`,
+		"http_server_response_size_bytes_bucket{" + labels + `,le="10"} 1`,
+		"http_server_response_size_bytes_sum{" + labels + "} 5",
+		"http_server_requests_in_flight 0",
+	} {
+		require.Contains(t, exposed, line+"\n")
+	}
+	require.NotContains(t, exposed, "worker_pool")
+}
+
+func TestServerMetrics(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	inFlight := make(chan string)
+	err = server.Start(server.SetHostPortOption("localhost", 18007),
+		server.SetMetricsOption(""),
+		server.SetMiddlewareWorkerPoolOption(2),
+		server.SetHandlerOption(ServerRoute{
+			Name:   "get_user",
+			Method: http.MethodGet,
+			Path:   "/users/{id}",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if PathParam(r, "id") == "slow" {
+					inFlight <- server.scrape(t)
+					return
+				}
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, "", nil)
+			},
+		}))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		resp, err := hc.Get(server.URL + path)
+		require.Nil(t, err)
+		resp.Body.Close()
+	}
+	resp, err := hc.Post(server.URL+"/users/1", ContentTypeText, strings.NewReader(""))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	for _, line := range []string{
+		`http_server_requests_total{route="get_user",method="GET",status="200"} 2`,
+		`http_server_requests_total{route="unmatched",method="GET",status="404"} 1`,
+		`http_server_requests_total{route="unmatched",method="POST",status="405"} 1`,
+	} {
+		// requests are recorded after their responses are sent
+		require.Contains(t, server.scrapeUntil(t, line), line)
+	}
+
+	go hc.Get(server.URL + "/users/slow")
+	var exposed string
+	select {
+	case exposed = <-inFlight:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow request is not served")
+	}
+	require.Contains(t, exposed, "http_server_requests_in_flight 2\n")
+	require.Contains(t, exposed, `http_server_worker_pool_active_jobs{pool="0"} 2`+"\n")
+	require.Contains(t, exposed, `http_server_worker_pool_max_workers{pool="0"} 2`+"\n")
+}
+
+func (s *Server) scrape(t *testing.T) string {
+	resp, err := http.Get(s.URL + MetricsPath)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, metricsContentType, resp.Header.Get(HeaderContentType))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	return string(body)
+}
+
+func (s *Server) scrapeUntil(t *testing.T, line string) (exposed string) {
+	for i := 0; i < 100; i++ {
+		if exposed = s.scrape(t); strings.Contains(exposed, line+"\n") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return exposed
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// Middleware wraps a handler with extra behavior
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one, the first middleware is the outermost
+// so it runs first on request and last on response
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			if middlewares[i] != nil {
+				handler = middlewares[i](handler)
+			}
+		}
+		return handler
+	}
+}
+
+// Use appends middlewares to the server chain which wraps every route, they run in
+// the order they are added and must be added before the server starts
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// SetMiddlewareOption set http server middlewares
+func (s *Server) SetMiddlewareOption(middlewares ...Middleware) StartServerOptions {
+	return func() (err error) {
+		s.Use(middlewares...)
+		return nil
+	}
+}
+
+// routeInfo holds the route resolved by the route dispatcher, it lets middlewares
+// which wrap the router read the route after the request is served
+type routeInfo struct {
+	name string
+}
+
+// withRouteInfo attaches an empty route holder to the request
+func withRouteInfo(r *http.Request) (*http.Request, *routeInfo) {
+	if info, ok := r.Context().Value(ContextRouteKey).(*routeInfo); ok {
+		return r, info
+	}
+	info := &routeInfo{}
+	return r.WithContext(context.WithValue(r.Context(), ContextRouteKey, info)), info
+}
+
+// RouteName returns name of the route serving the request, it is empty when no
+// route is matched or the request is not dispatched yet
+func RouteName(r *http.Request) string {
+	if info, ok := r.Context().Value(ContextRouteKey).(*routeInfo); ok {
+		return info.name
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	sdklog "github.com/hauxe/gom/log"
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactedValue replaces values of sensitive headers and query keys
+const redactedValue = "[REDACTED]"
+
+// B3 propagation headers used as trace ids when the request has no span
+const (
+	HeaderB3TraceID = "X-B3-TraceId"
+	HeaderB3SpanID  = "X-B3-SpanId"
+)
+
+// AccessLogConfig defines access log behavior
+type AccessLogConfig struct {
+	// SampleRate is the fraction of requests logged, server errors are always logged
+	SampleRate float64
+	// ExcludePaths are not logged, a path ending with slash excludes its subtree
+	ExcludePaths []string
+	// LogHeaders adds request headers to the entry
+	LogHeaders    bool
+	RedactHeaders []string
+	RedactQuery   []string
+}
+
+// DefaultAccessLogConfig returns config logging every request except health and
+// metrics endpoints
+func DefaultAccessLogConfig() *AccessLogConfig {
+	return &AccessLogConfig{
+		SampleRate:    1,
+		ExcludePaths:  []string{LivenessPath, ReadinessPath, MetricsPath},
+		RedactHeaders: []string{HeaderAuthorization, "Cookie", HeaderAPIKey, HeaderSignature},
+		RedactQuery:   []string{"token", "access_token", "api_key", "password"},
+	}
+}
+
+// AccessLogMiddleware http access log middleware, it emits one entry per request.
+// Trace ids are read from the request span so it should be added after the tracer
+type AccessLogMiddleware struct {
+	Handler http.Handler
+	Config  *AccessLogConfig
+	Logger  sdklog.Factory
+}
+
+// SetAccessLogOption set http server access log, nil config uses DefaultAccessLogConfig
+func (s *Server) SetAccessLogOption(config *AccessLogConfig) StartServerOptions {
+	return func() (err error) {
+		if config == nil {
+			config = DefaultAccessLogConfig()
+		}
+		s.Use(func(handler http.Handler) http.Handler {
+			return &AccessLogMiddleware{
+				Handler: handler,
+				Config:  config,
+				Logger:  s.Logger,
+			}
+		})
+		return nil
+	}
+}
+
+func (al *AccessLogMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if al.excluded(r.URL.Path) {
+		al.Handler.ServeHTTP(w, r)
+		return
+	}
+	start := time.Now()
+	r, info := withRouteInfo(r)
+	rw := newResponseWriter(w)
+	al.Handler.ServeHTTP(rw, r)
+	duration := time.Since(start)
+
+	status := rw.Status()
+	if status < http.StatusInternalServerError && !al.sampled() {
+		return
+	}
+	traceID, spanID := requestTraceIDs(r)
+	fields := []zapcore.Field{
+		zap.String("method", r.Method),
+		zap.String("route", info.name),
+		zap.String("path", r.URL.Path),
+		zap.String("query", al.Config.redactQuery(r.URL.RawQuery)),
+		zap.Int("status", status),
+		zap.Int64("bytes", rw.Size()),
+		zap.Duration("duration", duration),
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.String("user_agent", r.UserAgent()),
+		zap.String("trace_id", traceID),
+		zap.String("span_id", spanID),
+	}
+	if al.Config.LogHeaders {
+		fields = append(fields, zap.Any("headers", al.Config.redactHeaders(r.Header)))
+	}
+	logger := al.Logger.For(r.Context())
+	if status >= http.StatusInternalServerError {
+		logger.Error("http access", fields...)
+		return
+	}
+	logger.Info("http access", fields...)
+}
+
+func (al *AccessLogMiddleware) excluded(path string) bool {
+	for _, excludePath := range al.Config.ExcludePaths {
+		if path == excludePath ||
+			(strings.HasSuffix(excludePath, "/") && strings.HasPrefix(path, excludePath)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (al *AccessLogMiddleware) sampled() bool {
+	return al.Config.SampleRate >= 1 ||
+		(al.Config.SampleRate > 0 && rand.Float64() < al.Config.SampleRate)
+}
+
+func (c *AccessLogConfig) redactQuery(rawQuery string) string {
+	if rawQuery == "" || len(c.RedactQuery) == 0 {
+		return rawQuery
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// do not leak values which can not be parsed
+		return redactedValue
+	}
+	for key, values := range query {
+		if containsFold(c.RedactQuery, key) {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+	return query.Encode()
+}
+
+func (c *AccessLogConfig) redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key, values := range header {
+		if containsFold(c.RedactHeaders, key) {
+			headers[key] = redactedValue
+			continue
+		}
+		headers[key] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// requestTraceIDs returns trace and span ids of the request span, falling back to
+// B3 headers propagated by the caller
+func requestTraceIDs(r *http.Request) (traceID, spanID string) {
+	header := http.Header{}
+	if span := opentracing.SpanFromContext(r.Context()); span != nil {
+		err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders,
+			opentracing.HTTPHeadersCarrier(header))
+		if err != nil {
+			header = r.Header
+		}
+	} else {
+		header = r.Header
+	}
+	return header.Get(HeaderB3TraceID), header.Get(HeaderB3SpanID)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
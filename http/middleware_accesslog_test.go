@@ -0,0 +1,92 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdklog "github.com/hauxe/gom/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func createBufferLogger() (sdklog.Factory, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(buf), zap.InfoLevel)
+	return sdklog.Factory{Logger: zap.New(core)}, buf
+}
+
+func decodeLogEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		entry := map[string]interface{}{}
+		require.Nil(t, decoder.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	t.Parallel()
+	logger, buf := createBufferLogger()
+	config := DefaultAccessLogConfig()
+	config.LogHeaders = true
+	config.ExcludePaths = append(config.ExcludePaths, "/static/")
+	middleware := &AccessLogMiddleware{
+		Config: config,
+		Logger: logger,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, info := withRouteInfo(r)
+			info.name = "get_user"
+			if r.URL.Query().Get("fail") != "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte("hello"))
+		}),
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/users/1?token=secret&page=2", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer secret")
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set(HeaderB3TraceID, "463ac35c9f6413ad")
+	r.Header.Set(HeaderB3SpanID, "a2fb4a1d1a96d312")
+	middleware.ServeHTTP(httptest.NewRecorder(), r)
+	middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+
+	entries := decodeLogEntries(t, buf)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	require.Equal(t, "info", entry["level"])
+	require.Equal(t, "http access", entry["msg"])
+	require.Equal(t, http.MethodGet, entry["method"])
+	require.Equal(t, "get_user", entry["route"])
+	require.Equal(t, "/users/1", entry["path"])
+	require.Equal(t, "page=2&token=%5BREDACTED%5D", entry["query"])
+	require.Equal(t, float64(http.StatusOK), entry["status"])
+	require.Equal(t, float64(5), entry["bytes"])
+	require.Equal(t, "192.0.2.1:1234", entry["remote_addr"])
+	require.Equal(t, "test-agent", entry["user_agent"])
+	require.Equal(t, "463ac35c9f6413ad", entry["trace_id"])
+	require.Equal(t, "a2fb4a1d1a96d312", entry["span_id"])
+	headers, ok := entry["headers"].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, redactedValue, headers[HeaderAuthorization])
+	require.Equal(t, "test-agent", headers["User-Agent"])
+
+	t.Run("sampling keeps server errors", func(t *testing.T) {
+		config.SampleRate = 0
+		middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+		middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1?fail=1", nil))
+		entries := decodeLogEntries(t, buf)
+		require.Len(t, entries, 1)
+		require.Equal(t, "error", entries[0]["level"])
+		require.Equal(t, float64(http.StatusInternalServerError), entries[0]["status"])
+	})
+}
@@ -0,0 +1,305 @@
+package http
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// content codings
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingBrotli  = "br"
+)
+
+// CompressorFunc creates a writer compressing into w at the given level
+type CompressorFunc func(w io.Writer, level int) (io.WriteCloser, error)
+
+// compressors registered by content coding, brotli has no standard library
+// implementation so it is available once registered by the application
+var (
+	compressors = map[string]CompressorFunc{
+		EncodingGzip: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		EncodingDeflate: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		},
+	}
+	compressorsMux sync.RWMutex
+)
+
+// RegisterCompressor registers compressor of a content coding
+func RegisterCompressor(encoding string, compressor CompressorFunc) {
+	compressorsMux.Lock()
+	defer compressorsMux.Unlock()
+	compressors[encoding] = compressor
+}
+
+func compressorOf(encoding string) CompressorFunc {
+	compressorsMux.RLock()
+	defer compressorsMux.RUnlock()
+	return compressors[encoding]
+}
+
+// CompressionConfig defines response compression behavior
+type CompressionConfig struct {
+	// Encodings in server preference order, unregistered ones are skipped
+	Encodings []string
+	// Level is passed to the compressor, gzip and deflate accept -1 to 9
+	Level int
+	// MinSize is the smallest body in bytes which is compressed
+	MinSize int
+	// ContentTypes allowed to be compressed, an entry ending with slash allows
+	// every subtype
+	ContentTypes []string
+}
+
+// DefaultCompressionConfig returns config compressing text, JSON and XML bodies of
+// at least 1KB, event streams are never compressed
+func DefaultCompressionConfig() *CompressionConfig {
+	return &CompressionConfig{
+		Encodings: []string{EncodingBrotli, EncodingGzip, EncodingDeflate},
+		Level:     gzip.DefaultCompression,
+		MinSize:   1024,
+		ContentTypes: []string{
+			"text/",
+			ContentTypeJSON,
+			ContentTypeXML,
+			ContentTypeMsgPack,
+			"application/javascript",
+		},
+	}
+}
+
+// SetCompressionOption set http server compresses responses by Accept-Encoding, nil
+// config uses DefaultCompressionConfig
+func (s *Server) SetCompressionOption(config *CompressionConfig) StartServerOptions {
+	return func() (err error) {
+		if config == nil {
+			config = DefaultCompressionConfig()
+		}
+		s.Use(CompressionMiddleware(config))
+		return nil
+	}
+}
+
+// CompressionMiddleware creates middleware compressing responses with the coding the
+// client accepts, the body is buffered until MinSize bytes to decide
+func CompressionMiddleware(config *CompressionConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(HeaderVary, HeaderAcceptEncoding)
+			encoding := negotiateEncoding(r.Header.Get(HeaderAcceptEncoding), config.Encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				config:         config,
+				encoding:       encoding,
+				status:         http.StatusOK,
+			}
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					// buffered body is dropped without writing the header so
+					// recovery can still reply
+					cw.buf = nil
+					panic(recovered)
+				}
+				cw.Close()
+			}()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the registered encoding with the highest quality,
+// ties are broken by server preference
+func negotiateEncoding(acceptEncoding string, encodings []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ && compressorOf(encoding) != nil {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the beginning of the body to decide whether to compress
+type compressWriter struct {
+	http.ResponseWriter
+	config      *CompressionConfig
+	encoding    string
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	writer      io.WriteCloser
+}
+
+// WriteHeader records status code until compression is decided
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if !cw.wroteHeader {
+		cw.status = status
+		cw.wroteHeader = true
+	}
+}
+
+// Write buffers the body until MinSize then writes it compressed or as is
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.wroteHeader = true
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.config.MinSize {
+			return len(b), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// decide writes header and buffered body, compressing when the response qualifies
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+	if len(cw.buf) > 0 && header.Get(HeaderContentType) == "" {
+		header.Set(HeaderContentType, http.DetectContentType(cw.buf))
+	}
+	if cw.compressible(header) {
+		writer, err := compressorOf(cw.encoding)(cw.ResponseWriter, cw.config.Level)
+		if err != nil {
+			return errors.Wrap(err, "create compressor")
+		}
+		cw.writer = writer
+		header.Set(HeaderContentEncoding, cw.encoding)
+		header.Del(HeaderContentLength)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) compressible(header http.Header) bool {
+	if len(cw.buf) == 0 || len(cw.buf) < cw.config.MinSize ||
+		header.Get(HeaderContentEncoding) != "" ||
+		cw.status < http.StatusOK || cw.status == http.StatusNoContent ||
+		cw.status == http.StatusNotModified {
+		return false
+	}
+	contentType := strings.ToLower(header.Get(HeaderContentType))
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	if contentType == ContentTypeEventStream {
+		// events are flushed one by one, compressing would hold them back
+		return false
+	}
+	for _, allowed := range cw.config.ContentTypes {
+		if contentType == allowed ||
+			(strings.HasSuffix(allowed, "/") && strings.HasPrefix(contentType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close writes buffered body and finishes the compressed stream
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.writer != nil {
+		writer := cw.writer
+		cw.writer = nil
+		return writer.Close()
+	}
+	return nil
+}
+
+// Flush decides compression with the buffered body and flushes it to the client
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := cw.writer.(interface {
+		Flush() error
+	}); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the connection if the underlying writer supports it
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	cw.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
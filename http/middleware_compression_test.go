@@ -0,0 +1,168 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+	encodings := []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	testCases := []struct {
+		AcceptEncoding string
+		Expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", EncodingGzip},
+		{"deflate, gzip", EncodingGzip},
+		{"gzip;q=0.5, deflate", EncodingDeflate},
+		{"br", ""},
+		{"*", EncodingGzip},
+		{"*, gzip;q=0", EncodingDeflate},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.Expected, negotiateEncoding(tc.AcceptEncoding, encodings),
+			tc.AcceptEncoding)
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	t.Parallel()
+	RegisterCompressor("test", func(w io.Writer, level int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+	config := DefaultCompressionConfig()
+	config.Encodings = append([]string{"test"}, config.Encodings...)
+	large := strings.Repeat("compress me ", 200)
+	send := func(acceptEncoding, contentType, body string, status int) *httptest.ResponseRecorder {
+		handler := CompressionMiddleware(config)(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if contentType != "" {
+					w.Header().Set(HeaderContentType, contentType)
+				}
+				w.Header().Set(HeaderContentLength, "1")
+				w.WriteHeader(status)
+				// write in chunks across the size threshold
+				for i := 0; i < len(body); i += 100 {
+					end := i + 100
+					if end > len(body) {
+						end = len(body)
+					}
+					w.Write([]byte(body[i:end]))
+				}
+			}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderAcceptEncoding, acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("gzip", ContentTypeJSON, large, http.StatusCreated)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, EncodingGzip, w.Header().Get(HeaderContentEncoding))
+	require.Equal(t, HeaderAcceptEncoding, w.Header().Get(HeaderVary))
+	require.Empty(t, w.Header().Get(HeaderContentLength))
+	gr, err := gzip.NewReader(w.Body)
+	require.Nil(t, err)
+	body, err := ioutil.ReadAll(gr)
+	require.Nil(t, err)
+	require.Equal(t, large, string(body))
+
+	w = send("deflate", "", large, http.StatusOK)
+	require.Equal(t, EncodingDeflate, w.Header().Get(HeaderContentEncoding))
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get(HeaderContentType))
+	body, err = ioutil.ReadAll(flate.NewReader(w.Body))
+	require.Nil(t, err)
+	require.Equal(t, large, string(body))
+
+	w = send("test, gzip", ContentTypeJSON, large, http.StatusOK)
+	require.Equal(t, "test", w.Header().Get(HeaderContentEncoding))
+
+	// small body, disallowed content type and identity are sent as is
+	for _, tc := range []struct {
+		AcceptEncoding string
+		ContentType    string
+		Body           string
+	}{
+		{"gzip", ContentTypeJSON, `{"a":1}`},
+		{"gzip", "image/png", large},
+		{"gzip", ContentTypeEventStream, large},
+		{"identity", ContentTypeJSON, large},
+	} {
+		w = send(tc.AcceptEncoding, tc.ContentType, tc.Body, http.StatusOK)
+		require.Empty(t, w.Header().Get(HeaderContentEncoding))
+		require.Equal(t, tc.Body, w.Body.String())
+	}
+
+	w = send("gzip", "", "", http.StatusNoContent)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get(HeaderContentEncoding))
+	require.Equal(t, 0, w.Body.Len())
+}
+
+func TestCompressionRecovery(t *testing.T) {
+	t.Parallel()
+	logger, _ := createBufferLogger()
+	handler := Chain(
+		func(next http.Handler) http.Handler {
+			return &RecoveryMiddleware{Handler: next, Logger: logger}
+		},
+		CompressionMiddleware(DefaultCompressionConfig()),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderAcceptEncoding, acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusInternalServerError, w.Code, acceptEncoding)
+		require.Empty(t, w.Header().Get(HeaderContentEncoding), acceptEncoding)
+		dest := ServerResponse{}
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest), acceptEncoding)
+		require.Equal(t, ErrorCodeInternalError, dest.ErrorCode, acceptEncoding)
+	}
+}
+
+func TestServerCompression(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	err = server.Start(server.SetHostPortOption("localhost", 18010),
+		server.SetCompressionOption(nil),
+		server.SetHandlerOption(ServerRoute{Name: "large", Method: http.MethodGet, Path: "/large",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, strings.Repeat("a", 2048), nil)
+			}}))
+	require.Nil(t, err)
+	defer server.Stop()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/large", nil)
+	require.Nil(t, err)
+	req.Header.Set(HeaderAccept, ContentTypeXML)
+	req.Header.Set(HeaderAcceptEncoding, EncodingGzip)
+	resp, err := (&http.Client{}).Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, EncodingGzip, resp.Header.Get(HeaderContentEncoding))
+	require.Equal(t, ContentTypeXML, resp.Header.Get(HeaderContentType))
+	gr, err := gzip.NewReader(resp.Body)
+	require.Nil(t, err)
+	body, err := ioutil.ReadAll(gr)
+	require.Nil(t, err)
+	require.True(t, bytes.HasPrefix(body, []byte("<?xml")))
+	require.Contains(t, string(body), strings.Repeat("a", 2048))
+}
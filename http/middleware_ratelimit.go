@@ -0,0 +1,106 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	sdklog "github.com/hauxe/gom/log"
+	"github.com/hauxe/gom/ratelimit"
+	"go.uber.org/zap"
+)
+
+// RateLimitKeyFunc returns the client key of the request, empty key skips limiting
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimit defines request rate limit of a client
+type RateLimit struct {
+	ratelimit.Policy
+	// Key defaults to RateLimitByIP
+	Key RateLimitKeyFunc
+	// Store defaults to a memory store
+	Store ratelimit.Store
+}
+
+// RateLimitByIP keys requests by client ip
+func RateLimitByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitByHeader keys requests by header value, requests without the header
+// are keyed by client ip
+func RateLimitByHeader(header string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(header); value != "" {
+			return header + ":" + value
+		}
+		return RateLimitByIP(r)
+	}
+}
+
+// SetRateLimitOption set http server rate limit shared by every route
+func (s *Server) SetRateLimitOption(limit *RateLimit) StartServerOptions {
+	return func() (err error) {
+		middleware, err := RateLimitMiddleware("server", limit, s.Logger)
+		if err != nil {
+			return err
+		}
+		s.Use(middleware)
+		return nil
+	}
+}
+
+// RateLimitMiddleware creates rate limit middleware, name separates the quota from
+// other limits using the same store. Requests are allowed when the store fails
+func RateLimitMiddleware(name string, limit *RateLimit, logger sdklog.Factory) (Middleware, error) {
+	if limit == nil {
+		return nil, nil
+	}
+	if err := limit.Policy.Validate(); err != nil {
+		return nil, err
+	}
+	key, store := limit.Key, limit.Store
+	if key == nil {
+		key = RateLimitByIP
+	}
+	if store == nil {
+		store = ratelimit.NewMemoryStore()
+	}
+	policy := limit.Policy
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientKey := key(r)
+			if clientKey == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result, err := store.Take(r.Context(), name+":"+clientKey, policy)
+			if err != nil {
+				logger.For(r.Context()).Error("rate limit", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			header := w.Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			header.Set(HeaderRateLimitReset, ceilSeconds(result.Reset))
+			if !result.Allowed {
+				header.Set(HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+				SendResponse(w, http.StatusTooManyRequests, ErrorCodeTooManyRequests,
+					"rate limit exceeded", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
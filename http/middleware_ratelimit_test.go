@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hauxe/gom/ratelimit"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(_ context.Context, _ string, _ ratelimit.Policy) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store is down")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Parallel()
+	logger, _ := createBufferLogger()
+	_, err := RateLimitMiddleware("invalid", &RateLimit{}, logger)
+	require.Error(t, err)
+	middleware, err := RateLimitMiddleware("nil", nil, logger)
+	require.Nil(t, err)
+	require.Nil(t, middleware)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware, err = RateLimitMiddleware("failing", &RateLimit{
+		Policy: ratelimit.Policy{Limit: 1, Window: time.Minute},
+		Store:  failingStore{},
+	}, logger)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	middleware(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get(HeaderRateLimitLimit))
+}
+
+func TestServerRateLimit(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	store := ratelimit.NewMemoryStore()
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		SendResponse(w, http.StatusOK, ErrorCodeSuccess, "", nil)
+	}
+	err = server.Start(server.SetHostPortOption("localhost", 18008),
+		server.SetRateLimitOption(&RateLimit{
+			Policy: ratelimit.Policy{Limit: 3, Window: time.Minute},
+			Key:    RateLimitByHeader("X-Api-Key"),
+			Store:  store,
+		}),
+		server.SetHandlerOption(
+			ServerRoute{Name: "list", Method: http.MethodGet, Path: "/limited", Handler: handler},
+			ServerRoute{Name: "login", Method: http.MethodPost, Path: "/limited", Handler: handler,
+				RateLimit: &RateLimit{
+					Policy: ratelimit.Policy{
+						Algorithm: ratelimit.SlidingWindow,
+						Limit:     1,
+						Window:    time.Minute,
+					},
+					Store: store,
+				}},
+		))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+	send := func(method, apiKey string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+"/limited", nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Api-Key", apiKey)
+		resp, err := hc.Do(req)
+		require.Nil(t, err)
+		return resp
+	}
+
+	resp := send(http.MethodPost, "key1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// route limit is checked after server limit so it reports the route quota
+	require.Equal(t, "1", resp.Header.Get(HeaderRateLimitLimit))
+	require.Equal(t, "0", resp.Header.Get(HeaderRateLimitRemaining))
+
+	resp = send(http.MethodPost, "key2")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get(HeaderRetryAfter))
+	dest := ServerResponse{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+	require.Equal(t, ErrorCodeTooManyRequests, dest.ErrorCode)
+
+	// server quota of key1 is shared by every route
+	resp = send(http.MethodGet, "key1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "3", resp.Header.Get(HeaderRateLimitLimit))
+	require.Equal(t, "1", resp.Header.Get(HeaderRateLimitRemaining))
+	require.Equal(t, "40", resp.Header.Get(HeaderRateLimitReset))
+
+	resp = send(http.MethodGet, "key1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = send(http.MethodGet, "key1")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "20", resp.Header.Get(HeaderRetryAfter))
+
+	resp = send(http.MethodGet, "key2")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	lib "github.com/hauxe/gom/library"
+	sdklog "github.com/hauxe/gom/log"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+// RecoveryMiddleware http panic recovery middleware, it replies internal error
+// instead of dropping the connection. The request span is marked as errored so it
+// should be added after the tracer
+type RecoveryMiddleware struct {
+	Handler http.Handler
+	Logger  sdklog.Factory
+	// Debug includes the stack trace in the response
+	Debug bool
+}
+
+// SetRecoveryOption set http server recovers from handler panics
+func (s *Server) SetRecoveryOption(debug bool) StartServerOptions {
+	return func() (err error) {
+		s.Use(func(handler http.Handler) http.Handler {
+			return &RecoveryMiddleware{
+				Handler: handler,
+				Logger:  s.Logger,
+				Debug:   debug,
+			}
+		})
+		return nil
+	}
+}
+
+func (recovery *RecoveryMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := newResponseWriter(w)
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			// aborted by the handler on purpose, the server drops the connection
+			panic(recovered)
+		}
+		err := fmt.Errorf("unexpected %v", recovered)
+		trace := lib.StackTrace()
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			ext.Error.Set(span, true)
+		}
+		logger := recovery.Logger.For(r.Context())
+		logger.Error("handler panic", zap.Error(err), zap.String("method", r.Method),
+			zap.String("path", r.URL.Path), zap.String("trace", trace))
+		if rw.wroteHeader {
+			// response is partially sent, nothing can be fixed
+			return
+		}
+		var data map[string]interface{}
+		if recovery.Debug {
+			data = map[string]interface{}{
+				"error": trace,
+			}
+		}
+		if err = SendResponse(rw, http.StatusInternalServerError, ErrorCodeInternalError,
+			"internal server error", data); err != nil {
+			logger.Error("send response", zap.Error(err))
+		}
+	}()
+	recovery.Handler.ServeHTTP(rw, r)
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	t.Parallel()
+	logger, buf := createBufferLogger()
+	tracer := mocktracer.New()
+	panicHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/partial":
+			w.WriteHeader(http.StatusAccepted)
+		case "/abort":
+			panic(http.ErrAbortHandler)
+		}
+		panic("boom")
+	})
+	send := func(debug bool, path string) (*httptest.ResponseRecorder, ServerResponse) {
+		recovery := &RecoveryMiddleware{Handler: panicHandler, Logger: logger, Debug: debug}
+		span := tracer.StartSpan("test")
+		defer span.Finish()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+		w := httptest.NewRecorder()
+		recovery.ServeHTTP(w, r)
+		resp := ServerResponse{}
+		if w.Code == http.StatusInternalServerError {
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w, resp
+	}
+
+	w, resp := send(false, "/")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, ContentTypeJSON, w.Header().Get(HeaderContentType))
+	require.Equal(t, ErrorCodeInternalError, resp.ErrorCode)
+	require.Nil(t, resp.Data.Error)
+
+	w, resp = send(true, "/")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	stack, ok := resp.Data.Error.(string)
+	require.True(t, ok)
+	require.Contains(t, stack, "TestRecoveryMiddleware")
+
+	w, _ = send(true, "/partial")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Empty(t, w.Body.String())
+
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 3)
+	for _, span := range spans {
+		require.Equal(t, true, span.Tag("error"))
+	}
+	entries := decodeLogEntries(t, buf)
+	require.Len(t, entries, 3)
+	require.Equal(t, "handler panic", entries[0]["msg"])
+	require.Equal(t, "unexpected boom", entries[0]["error"])
+
+	// aborted handlers are not recovered nor logged
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		send(false, "/abort")
+	})
+	require.Empty(t, decodeLogEntries(t, buf))
+}
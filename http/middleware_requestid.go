@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+
+	lib "github.com/hauxe/gom/library"
+)
+
+// RequestIDMiddleware accepts the caller request id or generates one, stores it in
+// the request context and echoes it in the response header
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderRequestID)
+		if !lib.ValidRequestID(requestID) {
+			requestID = lib.NewRequestID()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+		next.ServeHTTP(w, r.WithContext(lib.ContextWithRequestID(r.Context(), requestID)))
+	})
+}
+
+// RequestID returns id of the request
+func RequestID(r *http.Request) string {
+	return lib.RequestIDFromContext(r.Context())
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+	var requestID string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		requestID = RequestID(r)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderRequestID, "caller-id")
+	handler.ServeHTTP(w, r)
+	require.Equal(t, "caller-id", requestID)
+	require.Equal(t, "caller-id", w.Header().Get(HeaderRequestID))
+
+	for _, header := range []string{"", "invalid id"} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderRequestID, header)
+		handler.ServeHTTP(w, r)
+		require.True(t, lib.ValidRequestID(requestID))
+		require.NotEqual(t, header, requestID)
+		require.Equal(t, requestID, w.Header().Get(HeaderRequestID))
+	}
+}
+
+func TestClientForwardRequestID(t *testing.T) {
+	t.Parallel()
+	server := CreateSampleServer(ServerRoute{
+		Path: "/request_id",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Header.Get(HeaderRequestID)))
+		},
+	})
+	client, err := CreateClient()
+	require.Nil(t, err)
+	require.Nil(t, client.Connect())
+	ctx := lib.ContextWithRequestID(context.Background(), "forwarded-id")
+
+	resp, err := client.Send(ctx, http.MethodGet, server.URL+"/request_id")
+	require.Nil(t, err)
+	body, err := ReadBodyString(resp)
+	require.Nil(t, err)
+	require.Equal(t, "forwarded-id", body)
+
+	resp, err = client.Send(ctx, http.MethodGet, server.URL+"/request_id",
+		client.SetRequestOptionHeader(map[string]interface{}{HeaderRequestID: "own-id"}))
+	require.Nil(t, err)
+	body, err = ReadBodyString(resp)
+	require.Nil(t, err)
+	require.Equal(t, "own-id", body)
+}
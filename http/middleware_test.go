@@ -0,0 +1,100 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type middlewareRecorder struct {
+	calls []string
+	mu    sync.Mutex
+}
+
+func (rec *middlewareRecorder) middleware(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec.record(name + " before")
+			next.ServeHTTP(w, r)
+			rec.record(name + " after")
+		})
+	}
+}
+
+func (rec *middlewareRecorder) record(call string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.calls = append(rec.calls, call)
+}
+
+func (rec *middlewareRecorder) reset() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	calls := rec.calls
+	rec.calls = nil
+	return calls
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+	rec := &middlewareRecorder{}
+	handler := Chain(rec.middleware("m1"), nil, rec.middleware("m2"))(
+		http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			rec.record("handler")
+		}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, []string{"m1 before", "m2 before", "handler", "m2 after", "m1 after"},
+		rec.reset())
+}
+
+func TestServerMiddlewares(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	rec := &middlewareRecorder{}
+	server.Use(rec.middleware("global1"))
+	routes := []ServerRoute{
+		ServerRoute{
+			Name:        "with_middlewares",
+			Method:      http.MethodGet,
+			Path:        "/middlewares",
+			Middlewares: []Middleware{rec.middleware("route1"), rec.middleware("route2")},
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				rec.record("handler")
+				w.WriteHeader(http.StatusOK)
+			},
+		},
+		ServerRoute{
+			Name:   "without_middlewares",
+			Method: http.MethodPost,
+			Path:   "/middlewares",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				rec.record("handler")
+				w.WriteHeader(http.StatusOK)
+			},
+		},
+	}
+	err = server.Start(server.SetHostPortOption("localhost", 18002),
+		server.SetHandlerOption(routes...),
+		server.SetMiddlewareOption(rec.middleware("global2")))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+
+	resp, err := hc.Get(server.URL + "/middlewares")
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"global1 before", "global2 before", "route1 before",
+		"route2 before", "handler", "route2 after", "route1 after", "global2 after",
+		"global1 after"}, rec.reset())
+
+	resp, err = hc.Post(server.URL+"/middlewares", ContentTypeText, strings.NewReader(""))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"global1 before", "global2 before", "handler",
+		"global2 after", "global1 after"}, rec.reset())
+}
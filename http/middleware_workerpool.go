@@ -6,6 +6,7 @@ import (
 
 	sdklog "github.com/hauxe/gom/log"
 	"github.com/hauxe/gom/pool"
+	"go.uber.org/zap"
 )
 
 // WorkerPoolMiddleware http worker pool middleware
@@ -22,9 +23,16 @@ func (worker *WorkerPoolMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Req
 		r:       r,
 		w:       w,
 		handler: worker.Handler.ServeHTTP,
-		c:       make(chan struct{}, 1),
+		c:       make(chan interface{}, 1),
+	}
+	if err := worker.Pool.QueueJob(&job, worker.Timeout); err != nil {
+		if err = SendError(w, err); err != nil {
+			worker.Logger.For(r.Context()).Error("queue job error", zap.Error(err))
+		}
+		return
+	}
+	// re-raise on the request goroutine so recovery middleware handles it
+	if recovered := <-job.c; recovered != nil {
+		panic(recovered)
 	}
-	worker.Pool.QueueJob(&job, worker.Timeout)
-	<-job.c
-	worker.Handler.ServeHTTP(w, r)
 }
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPoolMiddlewarePanic(t *testing.T) {
+	t.Parallel()
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetHostPortOption("127.0.0.1", 0),
+		server.SetRecoveryOption(false),
+		server.SetMiddlewareWorkerPoolOption(1),
+		server.SetHandlerOption(ServerRoute{
+			Name:   "panic",
+			Method: http.MethodGet,
+			Path:   "/panic",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("panic") != "" {
+					panic("boom")
+				}
+				SendResponse(w, http.StatusOK, ErrorCodeSuccess, "ok", nil)
+			}}))
+	require.Nil(t, err)
+	defer server.Stop()
+
+	hc := http.Client{}
+	resp, err := hc.Get(server.URL + "/panic?panic=1")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	dest := ServerResponse{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+	require.Equal(t, ErrorCodeInternalError, dest.ErrorCode)
+
+	// the only worker is still serving
+	resp, err = hc.Get(server.URL + "/panic")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Zero(t, server.WorkerPools[0].ActiveJobs())
+}
@@ -0,0 +1,546 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/hauxe/gom/library"
+)
+
+// OpenAPIPath is the default OpenAPI document endpoint
+const OpenAPIPath = "/openapi.json"
+
+// OpenAPIVersion is the OpenAPI specification version of generated documents
+const OpenAPIVersion = "3.0.3"
+
+// openAPIErrorCodes documents the response error codes
+var openAPIErrorCodes = []struct {
+	code ErrorCode
+	name string
+}{
+	{ErrorCodeFailed, "Failed"},
+	{ErrorCodeSuccess, "Success"},
+	{ErrorCodeInternalError, "InternalError"},
+	{ErrorCodeThirdPartyError, "ThirdPartyError"},
+	{ErrorCodeMalformedMethod, "MalformedMethod"},
+	{ErrorCodeBadRequest, "BadRequest"},
+	{ErrorCodeValidationFailed, "ValidationFailed"},
+	{ErrorCodeServiceUnavailable, "ServiceUnavailable"},
+	{ErrorCodeTooManyRequests, "TooManyRequests"},
+	{ErrorCodeUnauthorized, "Unauthorized"},
+	{ErrorCodeForbidden, "Forbidden"},
+	{ErrorCodeRequestTooLarge, "RequestTooLarge"},
+}
+
+// OpenAPIConfig defines the generated document and its endpoints
+type OpenAPIConfig struct {
+	Title       string
+	Version     string
+	Description string
+	// Path serves the document, empty path uses OpenAPIPath
+	Path string
+	// UIPath serves a Swagger UI page when it is set, it must end with slash
+	UIPath string
+	// UIAssetsURL serves swagger-ui-dist files of the page, empty URL uses the CDN of
+	// DefaultSwaggerUIAssetsURL. Set it to self-hosted files when the page must not
+	// load third party scripts
+	UIAssetsURL string
+}
+
+// OpenAPIDocument defines an OpenAPI 3 document
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo defines document metadata
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIComponents defines reusable schemas and security schemes
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPISecurityScheme defines an authentication method
+type OpenAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+// OpenAPIOperation defines a route
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+// OpenAPIParameter defines a path or query parameter
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody defines request body by content type
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse defines a response
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType defines the schema of a content type
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema defines a JSON schema subset
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	AllOf                []*OpenAPISchema          `json:"allOf,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+}
+
+// SetOpenAPIOption serves OpenAPI document of the server routes, the document is
+// generated on request so routes registered by later options are included
+func (s *Server) SetOpenAPIOption(config *OpenAPIConfig) StartServerOptions {
+	return func() (err error) {
+		if config == nil {
+			config = &OpenAPIConfig{}
+		}
+		if config.Path == "" {
+			config.Path = OpenAPIPath
+		}
+		routes := []ServerRoute{{
+			Name:   "openapi",
+			Method: http.MethodGet,
+			Path:   config.Path,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(HeaderContentType, ContentTypeJSON)
+				json.NewEncoder(w).Encode(s.OpenAPI(config))
+			},
+		}}
+		if config.UIAssetsURL == "" {
+			config.UIAssetsURL = DefaultSwaggerUIAssetsURL
+		}
+		if config.UIPath != "" {
+			ui := swaggerUIFileSystem(config.Path, config.UIAssetsURL)
+			routes = append(routes, ServerRoute{
+				Name:    "openapi_ui",
+				Method:  http.MethodGet,
+				Path:    config.UIPath,
+				Handler: GetFileSystemHandlerFS(ui, config.UIPath),
+			})
+		}
+		return s.SetHandlerOption(routes...)()
+	}
+}
+
+// OpenAPI generates OpenAPI document of the registered routes, request and response
+// schemas are reflected from the Request and Response of each route. Subtree
+// routes can not be described and are skipped
+func (s *Server) OpenAPI(config *OpenAPIConfig) *OpenAPIDocument {
+	g := &openAPIGenerator{schemas: map[string]*OpenAPISchema{}, names: map[reflect.Type]string{}}
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       config.Title,
+			Version:     config.Version,
+			Description: config.Description,
+		},
+		Paths:      map[string]map[string]*OpenAPIOperation{},
+		Components: OpenAPIComponents{Schemas: g.schemas},
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	g.schemas["ErrorCode"] = errorCodeSchema()
+	g.schemaOf(reflect.TypeOf(ServerResponse{}))
+	secured := false
+
+	s.routesMux.RLock()
+	defer s.routesMux.RUnlock()
+	// sorted so component names are stable
+	paths := make([]string, 0, len(s.definitions))
+	for path := range s.definitions {
+		if !strings.HasSuffix(path, "/") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		operations := map[string]*OpenAPIOperation{}
+		for _, method := range s.routeMethods(path) {
+			route := s.definitions[path][method]
+			if route.Auth != nil {
+				secured = true
+			}
+			operations[strings.ToLower(method)] = g.operation(route, method, path)
+		}
+		doc.Paths[openAPIPathOf(path)] = operations
+	}
+	if secured {
+		doc.Components.SecuritySchemes = map[string]*OpenAPISecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			"apiKeyAuth": {Type: "apiKey", Name: HeaderAPIKey, In: "header"},
+		}
+	}
+	return doc
+}
+
+// path parameters of route patterns, the wildcard suffix is not part of the name
+var pathParamPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+func openAPIPathOf(path string) string {
+	return pathParamPattern.ReplaceAllString(path, "{$1}")
+}
+
+type openAPIGenerator struct {
+	schemas map[string]*OpenAPISchema
+	names   map[reflect.Type]string
+}
+
+func (g *openAPIGenerator) operation(route ServerRoute, method, path string) *OpenAPIOperation {
+	operation := &OpenAPIOperation{
+		OperationID: route.Name,
+		Responses:   map[string]*OpenAPIResponse{},
+	}
+	pathParams := map[string]bool{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		pathParams[match[1]] = true
+		operation.Parameters = append(operation.Parameters, &OpenAPIParameter{
+			Name: match[1], In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"},
+		})
+	}
+	if route.Request != nil {
+		g.request(operation, reflect.TypeOf(route.Request), method, pathParams)
+	}
+
+	success := &OpenAPISchema{Ref: "#/components/schemas/ServerResponse"}
+	if route.Response != nil {
+		success = &OpenAPISchema{AllOf: []*OpenAPISchema{success, {
+			Type: "object",
+			Properties: map[string]*OpenAPISchema{"data": {
+				Type: "object",
+				Properties: map[string]*OpenAPISchema{
+					"success": g.schemaOf(reflect.TypeOf(route.Response)),
+				},
+			}},
+		}}}
+	}
+	operation.Responses["200"] = envelopeResponse("success", success)
+	errorResponse := func(status int) {
+		operation.Responses[strconv.Itoa(status)] = envelopeResponse(http.StatusText(status),
+			&OpenAPISchema{Ref: "#/components/schemas/ServerResponse"})
+	}
+	if route.Request != nil {
+		errorResponse(http.StatusBadRequest)
+	}
+	if route.Auth != nil {
+		errorResponse(http.StatusUnauthorized)
+		errorResponse(http.StatusForbidden)
+		operation.Security = []map[string][]string{
+			{"bearerAuth": route.Auth.Scopes},
+			{"apiKeyAuth": {}},
+		}
+	}
+	if route.Upload != nil {
+		errorResponse(http.StatusRequestEntityTooLarge)
+	}
+	if route.RateLimit != nil {
+		errorResponse(http.StatusTooManyRequests)
+	}
+	errorResponse(http.StatusInternalServerError)
+	return operation
+}
+
+// request documents fields of the request type as query parameters for methods
+// without body, otherwise as JSON or multipart body
+func (g *openAPIGenerator) request(operation *OpenAPIOperation, t reflect.Type, method string,
+	pathParams map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	hasBody := method != http.MethodGet && method != http.MethodDelete &&
+		method != http.MethodHead && method != http.MethodOptions
+	fields := structFields(t)
+	contentType := ContentTypeJSON
+	for _, field := range fields {
+		if field.Type == uploadedFileType || field.Type == uploadedFileSliceType {
+			contentType = ContentTypeMultipart
+		}
+	}
+	body := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for _, field := range fields {
+		if name := tagName(field, pathTag); name != "" && pathParams[name] {
+			for _, parameter := range operation.Parameters {
+				if parameter.Name == name {
+					parameter.Schema = g.fieldSchema(field)
+				}
+			}
+			continue
+		}
+		schema := g.fieldSchema(field)
+		required := containsString(strings.Split(field.Tag.Get(validateTag), ","), "required")
+		// query and form fields are named by schema tag
+		name := fieldName(field)
+		if !hasBody || contentType == ContentTypeMultipart {
+			if strings.Split(field.Tag.Get("schema"), ",")[0] == "-" {
+				continue
+			}
+			if name = tagName(field, "schema"); name == "" {
+				name = field.Name
+			}
+		}
+		if !hasBody {
+			operation.Parameters = append(operation.Parameters, &OpenAPIParameter{
+				Name: name, In: "query", Required: required, Schema: schema,
+			})
+			continue
+		}
+		body.Properties[name] = schema
+		if required {
+			body.Required = append(body.Required, name)
+		}
+	}
+	if hasBody {
+		operation.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{contentType: {Schema: body}},
+		}
+	}
+}
+
+func envelopeResponse(description string, schema *OpenAPISchema) *OpenAPIResponse {
+	return &OpenAPIResponse{
+		Description: description,
+		Content:     map[string]*OpenAPIMediaType{ContentTypeJSON: {Schema: schema}},
+	}
+}
+
+func errorCodeSchema() *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "integer"}
+	descriptions := make([]string, len(openAPIErrorCodes))
+	for i, errorCode := range openAPIErrorCodes {
+		schema.Enum = append(schema.Enum, int(errorCode.code))
+		descriptions[i] = strconv.Itoa(int(errorCode.code)) + ": " + errorCode.name
+	}
+	schema.Description = strings.Join(descriptions, ", ")
+	return schema
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	timeRFC3339Type = reflect.TypeOf(lib.TimeRFC3339{})
+	errorCodeType   = reflect.TypeOf(ErrorCode(0))
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaOf returns schema of t, named structs are added to components
+func (g *openAPIGenerator) schemaOf(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType, timeRFC3339Type:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case errorCodeType:
+		return &OpenAPISchema{Ref: "#/components/schemas/ErrorCode"}
+	case uploadedFileType.Elem():
+		return &OpenAPISchema{Type: "string", Format: "binary"}
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		// custom encoding can not be reflected
+		return &OpenAPISchema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			// registered before fields so recursive types refer to themselves
+			g.schemas[name] = &OpenAPISchema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	return &OpenAPISchema{}
+}
+
+func (g *openAPIGenerator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	parts := strings.Split(t.PkgPath(), "/")
+	name = parts[len(parts)-1] + "." + name
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[name]; !taken {
+			return name
+		}
+		name = parts[len(parts)-1] + "." + t.Name() + strconv.Itoa(i)
+	}
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for _, field := range structFields(t) {
+		name := fieldName(field)
+		schema.Properties[name] = g.fieldSchema(field)
+		if containsString(strings.Split(field.Tag.Get(validateTag), ","), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// fieldSchema returns schema of the field with its validate rules
+func (g *openAPIGenerator) fieldSchema(field reflect.StructField) *OpenAPISchema {
+	schema := g.schemaOf(field.Type)
+	tag := field.Tag.Get(validateTag)
+	if tag == "" || schema.Ref != "" {
+		return schema
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		if name == "dive" {
+			// following rules describe the elements
+			break
+		}
+		bound, err := strconv.ParseFloat(param, 64)
+		switch {
+		case name == "email":
+			schema.Format = "email"
+		case name == "regex":
+			schema.Pattern = param
+		case name == "oneof":
+			for _, option := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, option)
+			}
+		case err != nil:
+		case schema.Type == "string" || schema.Type == "array":
+			n := int(bound)
+			minimum, maximum := &schema.MinLength, &schema.MaxLength
+			if schema.Type == "array" {
+				minimum, maximum = &schema.MinItems, &schema.MaxItems
+			}
+			switch name {
+			case "min":
+				*minimum = &n
+			case "max":
+				*maximum = &n
+			case "len":
+				*minimum, *maximum = &n, &n
+			}
+		case name == "min":
+			schema.Minimum = &bound
+		case name == "max":
+			schema.Maximum = &bound
+		}
+	}
+	return schema
+}
+
+// structFields returns exported fields of t which are encoded, fields of embedded
+// structs are promoted
+func structFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, structFields(embedded)...)
+				continue
+			}
+		}
+		if field.PkgPath != "" || fieldName(field) == "" {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// tagName returns name of the field in tag, empty when it is not set or ignored
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIUser struct {
+	ID        int64          `json:"id" path:"id"`
+	Name      string         `json:"name" schema:"name" validate:"required,min=2,max=64"`
+	Email     string         `json:"email,omitempty" schema:"email" validate:"omitempty,email"`
+	Role      string         `json:"role" schema:"role" validate:"oneof=admin user"`
+	Tags      []string       `json:"tags" schema:"tags" validate:"max=3"`
+	Friends   []*openAPIUser `json:"friends,omitempty" schema:"-"`
+	CreatedAt time.Time      `json:"created_at" schema:"-"`
+	secret    string
+}
+
+type openAPIUpload struct {
+	Title string        `schema:"title"`
+	File  *UploadedFile `schema:"file" validate:"required"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	err = server.Start(server.SetHostPortOption("localhost", 18011),
+		server.SetOpenAPIOption(&OpenAPIConfig{Title: "users", Version: "1.2.0", UIPath: "/docs/"}),
+		server.SetHandlerOption(
+			ServerRoute{Name: "get_user", Method: http.MethodGet, Path: "/users/{id}",
+				Handler: noop, Request: openAPIUser{}, Response: &openAPIUser{}},
+			ServerRoute{Name: "update_user", Method: http.MethodPut, Path: "/users/{id}",
+				Handler: noop, Request: openAPIUser{}, Auth: &AuthRequirement{Scopes: []string{"users:write"}}},
+			ServerRoute{Name: "upload", Method: http.MethodPost, Path: "/files/{path...}",
+				Handler: noop, Request: &openAPIUpload{}, Upload: DefaultUploadConfig()},
+		))
+	require.Nil(t, err)
+	defer server.Stop()
+
+	resp, err := http.Get(server.URL + OpenAPIPath)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	doc := map[string]interface{}{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&doc))
+	get := func(value interface{}, keys ...interface{}) interface{} {
+		for _, key := range keys {
+			switch key := key.(type) {
+			case string:
+				value = value.(map[string]interface{})[key]
+			case int:
+				value = value.([]interface{})[key]
+			}
+		}
+		return value
+	}
+
+	require.Equal(t, OpenAPIVersion, doc["openapi"])
+	require.Equal(t, map[string]interface{}{"title": "users", "version": "1.2.0"}, doc["info"])
+	paths := doc["paths"].(map[string]interface{})
+	require.Contains(t, paths, OpenAPIPath)
+	require.Contains(t, paths, "/files/{path}")
+	require.NotContains(t, paths, "/docs/")
+
+	getUser := get(doc, "paths", "/users/{id}", "get")
+	require.Equal(t, "get_user", get(getUser, "operationId"))
+	require.Equal(t, map[string]interface{}{
+		"name": "id", "in": "path", "required": true,
+		"schema": map[string]interface{}{"type": "integer", "format": "int64"},
+	}, get(getUser, "parameters", 0))
+	require.Equal(t, map[string]interface{}{
+		"name": "name", "in": "query", "required": true,
+		"schema": map[string]interface{}{"type": "string", "minLength": float64(2), "maxLength": float64(64)},
+	}, get(getUser, "parameters", 1))
+	require.Len(t, get(getUser, "parameters"), 5)
+	require.Equal(t, "#/components/schemas/ServerResponse",
+		get(getUser, "responses", "200", "content", ContentTypeJSON, "schema", "allOf", 0, "$ref"))
+	require.Equal(t, "#/components/schemas/openAPIUser",
+		get(getUser, "responses", "200", "content", ContentTypeJSON, "schema", "allOf", 1,
+			"properties", "data", "properties", "success", "$ref"))
+	require.Contains(t, get(getUser, "responses"), "400")
+
+	updateUser := get(doc, "paths", "/users/{id}", "put")
+	body := get(updateUser, "requestBody", "content", ContentTypeJSON, "schema")
+	require.Equal(t, []interface{}{"name"}, get(body, "required"))
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "email"},
+		get(body, "properties", "email"))
+	require.Equal(t, []interface{}{"admin", "user"}, get(body, "properties", "role", "enum"))
+	require.NotContains(t, get(body, "properties"), "id")
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"bearerAuth": []interface{}{"users:write"}},
+		map[string]interface{}{"apiKeyAuth": []interface{}{}},
+	}, get(updateUser, "security"))
+	require.Contains(t, get(updateUser, "responses"), "401")
+	require.Contains(t, get(updateUser, "responses"), "403")
+
+	upload := get(doc, "paths", "/files/{path}", "post")
+	body = get(upload, "requestBody", "content", ContentTypeMultipart, "schema")
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "binary"},
+		get(body, "properties", "file"))
+	require.Equal(t, []interface{}{"file"}, get(body, "required"))
+	require.Contains(t, get(upload, "responses"), "413")
+
+	user := get(doc, "components", "schemas", "openAPIUser")
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "date-time"},
+		get(user, "properties", "created_at"))
+	require.Equal(t, "#/components/schemas/openAPIUser", get(user, "properties", "friends", "items", "$ref"))
+	require.NotContains(t, get(user, "properties"), "secret")
+	require.Equal(t, "#/components/schemas/ErrorCode",
+		get(doc, "components", "schemas", "ServerResponse", "properties", "error_code", "$ref"))
+	require.Contains(t, get(doc, "components", "schemas", "ErrorCode", "enum"), float64(ErrorCodeForbidden))
+	require.Contains(t, get(doc, "components", "securitySchemes"), "bearerAuth")
+
+	resp, err = http.Get(server.URL + "/docs/")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	page, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Contains(t, string(page), "SwaggerUIBundle")
+	require.Contains(t, string(page), `"/openapi.json"`)
+	require.Contains(t, string(page), DefaultSwaggerUIAssetsURL+"/swagger-ui-bundle.js")
+
+	file, err := swaggerUIFileSystem("/openapi.json", "/static/swagger/").Open("/index.html")
+	require.Nil(t, err)
+	page, err = ioutil.ReadAll(file)
+	require.Nil(t, err)
+	require.Contains(t, string(page), `src="/static/swagger/swagger-ui-bundle.js"`)
+	require.NotContains(t, string(page), "unpkg.com")
+}
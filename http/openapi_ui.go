@@ -0,0 +1,112 @@
+package http
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultSwaggerUIAssetsURL is the unpkg CDN of swagger-ui-dist, pages using it load
+// scripts from a third party
+const DefaultSwaggerUIAssetsURL = "https://unpkg.com/swagger-ui-dist@3"
+
+// swaggerUIPage loads Swagger UI assets from AssetsURL and renders the document at
+// SpecURL
+var swaggerUIPage = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API documentation</title>
+  <link rel="stylesheet" href="{{.AssetsURL}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.AssetsURL}}/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({url: {{.SpecURL}}, dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`))
+
+// swaggerUIFileSystem returns file system of the Swagger UI page for the document
+// served at specURL, assets are loaded from assetsURL
+func swaggerUIFileSystem(specURL, assetsURL string) http.FileSystem {
+	buf := &bytes.Buffer{}
+	swaggerUIPage.Execute(buf, struct{ SpecURL, AssetsURL string }{specURL,
+		strings.TrimSuffix(assetsURL, "/")})
+	return memoryFileSystem{"/index.html": buf.Bytes()}
+}
+
+// memoryFileSystem serves files kept in memory by their absolute path
+type memoryFileSystem map[string][]byte
+
+// Open opens file or directory containing files
+func (fs memoryFileSystem) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	if content, ok := fs[name]; ok {
+		return &memoryFile{Reader: bytes.NewReader(content), name: path.Base(name),
+			size: int64(len(content))}, nil
+	}
+	dir := strings.TrimSuffix(name, "/") + "/"
+	for file := range fs {
+		if strings.HasPrefix(file, dir) {
+			return &memoryFile{Reader: bytes.NewReader(nil), name: path.Base(name), dir: true}, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// memoryFile implements http.File and os.FileInfo of a memory file
+type memoryFile struct {
+	*bytes.Reader
+	name string
+	size int64
+	dir  bool
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
+
+func (f *memoryFile) Readdir(count int) ([]os.FileInfo, error) {
+	// listing is not supported, directories are served by their index
+	return nil, os.ErrPermission
+}
+
+func (f *memoryFile) Stat() (os.FileInfo, error) {
+	return f, nil
+}
+
+func (f *memoryFile) Name() string {
+	return f.name
+}
+
+func (f *memoryFile) Size() int64 {
+	return f.size
+}
+
+func (f *memoryFile) Mode() os.FileMode {
+	if f.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (f *memoryFile) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (f *memoryFile) IsDir() bool {
+	return f.dir
+}
+
+func (f *memoryFile) Sys() interface{} {
+	return nil
+}
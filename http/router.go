@@ -0,0 +1,282 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/schema"
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+const pathTag = "path"
+
+// pathDecoder decodes captured path parameters into fields tagged with `path`
+var pathDecoder = func() *schema.Decoder {
+	d := schema.NewDecoder()
+	d.SetAliasTag(pathTag)
+	d.IgnoreUnknownKeys(true)
+	d.ZeroEmpty(false)
+	return d
+}()
+
+// segment kinds ordered by matching precedence
+const (
+	segmentWildcard = iota
+	segmentParam
+	segmentLiteral
+)
+
+type patternSegment struct {
+	kind  int
+	value string
+}
+
+type routePattern struct {
+	path     string
+	segments []patternSegment
+	// subtree pattern ends with slash and matches every path below it
+	subtree bool
+	handler http.Handler
+}
+
+// Router dispatches requests to handlers registered on path templates such as
+// /users/{id} or /files/{path...}, unmatched requests are passed to NotFound
+type Router struct {
+	NotFound http.Handler
+	routes   []*routePattern
+	mux      sync.RWMutex
+}
+
+// NewRouter creates router, notFound handles requests which match no pattern
+func NewRouter(notFound http.Handler) *Router {
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	return &Router{NotFound: notFound}
+}
+
+// Handle registers handler for the path pattern
+func (rt *Router) Handle(pattern string, handler http.Handler) error {
+	if handler == nil {
+		return errors.New(lib.StringTags("router handle", pattern, "nil handler"))
+	}
+	p, err := parsePattern(pattern)
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("router handle", pattern))
+	}
+	p.handler = handler
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+	for _, existed := range rt.routes {
+		if existed.conflicts(p) {
+			return errors.Errorf("%s conflicts with registered pattern %s", pattern, existed.path)
+		}
+	}
+	rt.routes = append(rt.routes, p)
+	return nil
+}
+
+// HandleFunc registers handler function for the path pattern
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) error {
+	if handler == nil {
+		return errors.New(lib.StringTags("router handle", pattern, "nil handler"))
+	}
+	return rt.Handle(pattern, handler)
+}
+
+// Match finds the most specific pattern matching the path and its captured parameters
+func (rt *Router) Match(path string) (pattern string, params map[string]string, handler http.Handler) {
+	segments := splitPath(path)
+	rt.mux.RLock()
+	defer rt.mux.RUnlock()
+	var best *routePattern
+	for _, p := range rt.routes {
+		if !p.match(segments) {
+			continue
+		}
+		if best == nil || p.moreSpecific(best) {
+			best = p
+		}
+	}
+	if best == nil {
+		return "", nil, nil
+	}
+	return best.path, best.params(segments), best.handler
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, params, handler := rt.Match(r.URL.EscapedPath())
+	if handler == nil {
+		rt.NotFound.ServeHTTP(w, r)
+		return
+	}
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), ContextPathParamsKey, params))
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// PathParam returns the path parameter captured by the route pattern
+func PathParam(r *http.Request, name string) string {
+	return PathParams(r)[name]
+}
+
+// PathParams returns all path parameters captured by the route pattern
+func PathParams(r *http.Request) map[string]string {
+	if params, ok := r.Context().Value(ContextPathParamsKey).(map[string]string); ok {
+		return params
+	}
+	return nil
+}
+
+func decodePathParams(r *http.Request, dst interface{}) error {
+	params := PathParams(r)
+	if len(params) == 0 {
+		return nil
+	}
+	values := make(url.Values, len(params))
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return pathDecoder.Decode(dst, values)
+}
+
+func parsePattern(pattern string) (*routePattern, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, errors.New("pattern must begin with slash")
+	}
+	p := &routePattern{path: pattern}
+	parts := strings.Split(pattern[1:], "/")
+	if parts[len(parts)-1] == "" {
+		p.subtree = true
+		parts = parts[:len(parts)-1]
+	}
+	names := make(map[string]struct{}, len(parts))
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, errors.Errorf("invalid segment %s", part)
+			}
+			p.segments = append(p.segments, patternSegment{segmentLiteral, part})
+			continue
+		}
+		if !strings.HasSuffix(part, "}") {
+			return nil, errors.Errorf("unclosed parameter in segment %s", part)
+		}
+		name := part[1 : len(part)-1]
+		kind := segmentParam
+		if strings.HasSuffix(name, "...") {
+			if i != len(parts)-1 || p.subtree {
+				return nil, errors.Errorf("wildcard %s must be the last segment", part)
+			}
+			name = strings.TrimSuffix(name, "...")
+			kind = segmentWildcard
+		}
+		if name == "" || strings.ContainsAny(name, "{}") {
+			return nil, errors.Errorf("invalid parameter name in segment %s", part)
+		}
+		if _, existed := names[name]; existed {
+			return nil, errors.Errorf("duplicate parameter %s", name)
+		}
+		names[name] = struct{}{}
+		p.segments = append(p.segments, patternSegment{kind, name})
+	}
+	return p, nil
+}
+
+func splitPath(path string) []string {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = unescaped
+		}
+	}
+	return parts
+}
+
+func (p *routePattern) wildcard() bool {
+	n := len(p.segments)
+	return p.subtree || (n > 0 && p.segments[n-1].kind == segmentWildcard)
+}
+
+func (p *routePattern) match(parts []string) bool {
+	n := len(p.segments)
+	switch {
+	case p.subtree:
+		// subtree requires the trailing slash after its own segments
+		if len(parts) <= n {
+			return false
+		}
+	case p.wildcard():
+		if len(parts) < n {
+			return false
+		}
+	case len(parts) != n:
+		return false
+	}
+	for i, s := range p.segments {
+		switch s.kind {
+		case segmentLiteral:
+			if parts[i] != s.value {
+				return false
+			}
+		case segmentParam:
+			if parts[i] == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *routePattern) params(parts []string) map[string]string {
+	var params map[string]string
+	for i, s := range p.segments {
+		if s.kind == segmentLiteral {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string, len(p.segments))
+		}
+		if s.kind == segmentWildcard {
+			params[s.value] = strings.Join(parts[i:], "/")
+			continue
+		}
+		params[s.value] = parts[i]
+	}
+	return params
+}
+
+// moreSpecific compares segment by segment, literal beats parameter beats wildcard,
+// then exact pattern beats wildcard pattern and longer pattern beats shorter one
+func (p *routePattern) moreSpecific(o *routePattern) bool {
+	for i := 0; i < len(p.segments) && i < len(o.segments); i++ {
+		if p.segments[i].kind != o.segments[i].kind {
+			return p.segments[i].kind > o.segments[i].kind
+		}
+	}
+	if p.wildcard() != o.wildcard() {
+		return !p.wildcard()
+	}
+	return len(p.segments) > len(o.segments)
+}
+
+// conflicts reports whether both patterns match exactly the same paths
+func (p *routePattern) conflicts(o *routePattern) bool {
+	if p.subtree != o.subtree || len(p.segments) != len(o.segments) {
+		return false
+	}
+	for i, s := range p.segments {
+		if s.kind != o.segments[i].kind {
+			return false
+		}
+		if s.kind == segmentLiteral && s.value != o.segments[i].value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterHandle(t *testing.T) {
+	t.Parallel()
+	f := func(_ http.ResponseWriter, _ *http.Request) {}
+	t.Run("error invalid patterns", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(nil)
+		for _, pattern := range []string{
+			"users",
+			"/users/{id",
+			"/users/{}",
+			"/users/x{id}",
+			"/users/{id}/{id}",
+			"/files/{path...}/meta",
+			"/files/{path...}/",
+		} {
+			require.Error(t, router.HandleFunc(pattern, f), pattern)
+		}
+	})
+
+	t.Run("error nil handler", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(nil)
+		require.Error(t, router.HandleFunc("/users", nil))
+	})
+
+	t.Run("error conflict pattern", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(nil)
+		require.Nil(t, router.HandleFunc("/users/{id}", f))
+		require.Error(t, router.HandleFunc("/users/{userID}", f))
+		require.Nil(t, router.HandleFunc("/users/me", f))
+		require.Error(t, router.HandleFunc("/users/me", f))
+	})
+}
+
+func TestRouterMatch(t *testing.T) {
+	t.Parallel()
+	f := func(_ http.ResponseWriter, _ *http.Request) {}
+	router := NewRouter(nil)
+	for _, pattern := range []string{
+		"/users",
+		"/users/me",
+		"/users/{id}",
+		"/orders/{orderID}/items/{itemID}",
+		"/files/{path...}",
+		"/static/",
+	} {
+		require.Nil(t, router.HandleFunc(pattern, f))
+	}
+	cases := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"/users", "/users", nil},
+		{"/users/me", "/users/me", nil},
+		{"/users/123", "/users/{id}", map[string]string{"id": "123"}},
+		{"/users/a%2Fb", "/users/{id}", map[string]string{"id": "a/b"}},
+		{"/orders/1/items/2", "/orders/{orderID}/items/{itemID}",
+			map[string]string{"orderID": "1", "itemID": "2"}},
+		{"/files/a/b/c.txt", "/files/{path...}", map[string]string{"path": "a/b/c.txt"}},
+		{"/static/css/app.css", "/static/", nil},
+		{"/users/", "", nil},
+		{"/orders/1/items", "", nil},
+		{"/unknown", "", nil},
+	}
+	for _, c := range cases {
+		pattern, params, handler := router.Match(c.path)
+		require.Equal(t, c.pattern, pattern, c.path)
+		require.Equal(t, c.params, params, c.path)
+		require.Equal(t, c.pattern != "", handler != nil, c.path)
+	}
+}
+
+func TestRouterServeHTTP(t *testing.T) {
+	t.Parallel()
+	notFound := http.NewServeMux()
+	notFound.HandleFunc("/fallback", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	router := NewRouter(notFound)
+	require.Nil(t, router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, map[string]string{"id": "42"}, PathParams(r))
+		w.Write([]byte(PathParam(r, "id")))
+	}))
+	t.Run("success path param", func(t *testing.T) {
+		t.Parallel()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "42", w.Body.String())
+	})
+
+	t.Run("success fallback", func(t *testing.T) {
+		t.Parallel()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fallback", nil))
+		require.Equal(t, http.StatusAccepted, w.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestServerPathParameters(t *testing.T) {
+	server, err := CreateServer()
+	require.Nil(t, err)
+	require.NotNil(t, server)
+	type item struct {
+		OrderID int64  `json:"order_id" path:"orderID"`
+		ItemID  string `json:"item_id" path:"itemID"`
+		Note    string `json:"note" schema:"note"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		dest := item{}
+		if err := ParseParameters(r, &dest); err != nil {
+			SendError(w, err)
+			return
+		}
+		SendResponse(w, http.StatusOK, ErrorCodeSuccess, "success", map[string]interface{}{
+			"success": dest,
+		})
+	}
+	err = server.Start(server.SetHostPortOption("localhost", 18001),
+		server.SetHandlerOption(
+			ServerRoute{
+				Name:    "get_item",
+				Method:  http.MethodGet,
+				Path:    "/orders/{orderID}/items/{itemID}",
+				Handler: handler,
+			},
+			ServerRoute{
+				Name:    "update_item",
+				Method:  http.MethodPut,
+				Path:    "/orders/{orderID}/items/{itemID}",
+				Handler: handler,
+			},
+		))
+	require.Nil(t, err)
+	defer server.Stop()
+	hc := http.Client{}
+	t.Run("success query", func(t *testing.T) {
+		resp, err := hc.Get(server.URL + "/orders/7/items/abc?note=hello")
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		dest := struct {
+			Data struct {
+				Success item `json:"success"`
+			} `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+		require.Equal(t, item{OrderID: 7, ItemID: "abc", Note: "hello"}, dest.Data.Success)
+	})
+
+	t.Run("success json body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/orders/8/items/xyz",
+			strings.NewReader(`{"note":"body","order_id":1}`))
+		require.Nil(t, err)
+		req.Header.Set(HeaderContentType, ContentTypeJSON)
+		resp, err := hc.Do(req.WithContext(context.Background()))
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		dest := struct {
+			Data struct {
+				Success item `json:"success"`
+			} `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+		require.Equal(t, item{OrderID: 8, ItemID: "xyz", Note: "body"}, dest.Data.Success)
+	})
+
+	t.Run("error invalid path param", func(t *testing.T) {
+		resp, err := hc.Get(server.URL + "/orders/abc/items/xyz")
+		require.Nil(t, err)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("error method not allowed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, server.URL+"/orders/8/items/xyz", nil)
+		require.Nil(t, err)
+		resp, err := hc.Do(req)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
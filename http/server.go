@@ -2,8 +2,10 @@ package http
 
 import (
 	"context"
-	"fmt"
+	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hauxe/gom/environment"
@@ -27,37 +29,72 @@ var decoder = schema.NewDecoder()
 
 const (
 	// default http server config
-	serverHost   = "localhost"
-	serverPort   = 8000
-	serveTLS     = false
-	certFile     = ""
-	keyFile      = ""
-	readTimeout  = 32
-	writeTimeout = 64
+	serverHost      = "localhost"
+	serverPort      = 8000
+	serveTLS        = false
+	certFile        = ""
+	keyFile         = ""
+	readTimeout     = 32
+	writeTimeout    = 64
+	shutdownTimeout = 30
 )
 
 // ServerConfig defines HTTP sever config value
 type ServerConfig struct {
-	Host         string `env:"HTTP_SERVER_HOST"`
-	Port         int    `env:"HTTP_SERVER_PORT"`
-	ServeTLS     bool   `env:"HTTP_SERVER_TLS"`
-	CertFile     string `env:"HTTP_SERVER_CERT"`
-	KeyFile      string `env:"HTTP_SERVER_KEY"`
-	ReadTimeout  int    `env:"HTTP_SERVER_READ_TIMEOUT"`
-	WriteTimeout int    `env:"HTTP_SERVER_WRITE_TIMEOUT"`
+	Host            string `env:"HTTP_SERVER_HOST"`
+	Port            int    `env:"HTTP_SERVER_PORT"`
+	ServeTLS        bool   `env:"HTTP_SERVER_TLS"`
+	CertFile        string `env:"HTTP_SERVER_CERT"`
+	KeyFile         string `env:"HTTP_SERVER_KEY"`
+	ReadTimeout     int    `env:"HTTP_SERVER_READ_TIMEOUT"`
+	WriteTimeout    int    `env:"HTTP_SERVER_WRITE_TIMEOUT"`
+	ShutdownTimeout int    `env:"HTTP_SERVER_SHUTDOWN_TIMEOUT"`
+	ShutdownDelay   int    `env:"HTTP_SERVER_SHUTDOWN_DELAY"`
+	CORS            *CORSConfig
+	// client certificate authentication and certificate reloading of TLS listeners,
+	// reload interval is in seconds
+	ClientCAFile          string   `env:"HTTP_SERVER_CLIENT_CA"`
+	ClientAuth            string   `env:"HTTP_SERVER_CLIENT_AUTH"`
+	AllowedClientSubjects []string `env:"HTTP_SERVER_CLIENT_SUBJECTS"`
+	CertReloadInterval    int      `env:"HTTP_SERVER_CERT_RELOAD_INTERVAL"`
+}
+
+// TLSConfig returns tls config of the server certificate and client authentication
+func (c *ServerConfig) TLSConfig() *lib.TLSConfig {
+	return &lib.TLSConfig{
+		CertFile:        c.CertFile,
+		KeyFile:         c.KeyFile,
+		ReloadInterval:  time.Duration(c.CertReloadInterval) * time.Second,
+		ClientCAFile:    c.ClientCAFile,
+		ClientAuth:      c.ClientAuth,
+		AllowedSubjects: c.AllowedClientSubjects,
+	}
 }
 
 // Server defines HTTP server properties
 type Server struct {
-	Config      *ServerConfig
-	S           *http.Server
-	Handler     http.Handler
-	Mux         *http.ServeMux
-	Logger      sdklog.Factory
-	WorkerPools []*pool.Worker
-	URL         string
-	Routes      map[string]map[string]http.HandlerFunc
-	routesMux   sync.RWMutex
+	Config       *ServerConfig
+	S            *http.Server
+	Handler      http.Handler
+	Router       *Router
+	Mux          *http.ServeMux
+	Logger       sdklog.Factory
+	WorkerPools  []*pool.Worker
+	Metrics      *Metrics
+	URL          string
+	Routes       map[string]map[string]http.HandlerFunc
+	definitions  map[string]map[string]ServerRoute
+	routesMux    sync.RWMutex
+	middlewares  []Middleware
+	listeners    []ListenerConfig
+	servers      []*http.Server // serve redirect listeners
+	tlsConfig    *lib.TLSConfig
+	certReloader *lib.CertificateReloader
+	addrs        []net.Addr
+	sockets      map[*WebSocketConn]struct{}
+	socketsMux   sync.Mutex
+	socketsWG    sync.WaitGroup
+	ready        int32
 }
 
 // CreateServer creates HTTP server
@@ -67,13 +104,16 @@ func CreateServer(options ...environment.CreateENVOptions) (server *Server, err
 		return nil, errors.Wrap(err, lib.StringTags("create server", "create env"))
 	}
 	config := ServerConfig{
-		serverHost,
-		serverPort,
-		serveTLS,
-		certFile,
-		keyFile,
-		readTimeout,
-		writeTimeout}
+		Host:            serverHost,
+		Port:            serverPort,
+		ServeTLS:        serveTLS,
+		CertFile:        certFile,
+		KeyFile:         keyFile,
+		ReadTimeout:     readTimeout,
+		WriteTimeout:    writeTimeout,
+		ShutdownTimeout: shutdownTimeout,
+		CORS:            DefaultCORSConfig(),
+	}
 	if err = env.Parse(&config); err != nil {
 		return nil, errors.Wrap(err, lib.StringTags("create server", "parse env"))
 	}
@@ -98,6 +138,10 @@ func (s *Server) Start(options ...StartServerOptions) (err error) {
 			return errors.Wrap(err, lib.StringTags("start server", "option error"))
 		}
 	}
+	// request id is set first so every middleware can log it, the content type is
+	// negotiated and cors headers are set before any middleware may send a response
+	s.Handler = Chain(append([]Middleware{RequestIDMiddleware, NegotiationMiddleware,
+		s.corsMiddleware}, s.middlewares...)...)(s.Handler)
 	decoder.IgnoreUnknownKeys(true)
 	decoder.ZeroEmpty(false)
 	address := lib.GetURL(s.Config.Host, s.Config.Port)
@@ -111,59 +155,129 @@ func (s *Server) Start(options ...StartServerOptions) (err error) {
 	readTimeout := time.Duration(s.Config.ReadTimeout) * time.Second
 	writeTimeout := time.Duration(s.Config.WriteTimeout) * time.Second
 
+	listeners := s.listeners
+	if len(listeners) == 0 {
+		listeners = []ListenerConfig{{Network: NetworkTCP, Address: address, TLS: s.Config.ServeTLS}}
+	}
 	s.S = &http.Server{
-		Addr:         address,
+		Addr:         listeners[0].Address,
 		Handler:      s.Handler,
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
+		ConnContext:  contextWithConn,
 	}
-	s.Logger.Bg().Info(fmt.Sprintf("Starting HTTP server at: %s:%d",
-		s.Config.Host, s.Config.Port))
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
-	go func() {
-		if s.Config.ServeTLS {
-			s.URL = fmt.Sprintf("%s://%s", "https", s.S.Addr)
-			wg.Done()
-			if err = s.S.ListenAndServeTLS(s.Config.CertFile, s.Config.KeyFile); err != nil {
-				if err != http.ErrServerClosed {
-					s.Logger.Bg().Info(fmt.Sprintf("Error Start TLS HTTP server at: %s:%d",
-						s.Config.Host, s.Config.Port))
-					s.Logger.Bg().Error(err.Error())
-				}
-			}
-		} else {
-			s.URL = fmt.Sprintf("%s://%s", "http", s.S.Addr)
-			wg.Done()
-			if err = s.S.ListenAndServe(); err != nil {
-				if err != http.ErrServerClosed {
-					s.Logger.Bg().Info(fmt.Sprintf("Error Start HTTP server at: %s:%d",
-						s.Config.Host, s.Config.Port))
-					s.Logger.Bg().Error(err.Error())
-				}
-			}
+	defer func() {
+		if err != nil && s.certReloader != nil {
+			s.certReloader.Close()
 		}
 	}()
-	wg.Wait()
+	for _, listener := range listeners {
+		if listener.TLS {
+			if err = s.loadTLSConfig(); err != nil {
+				return errors.Wrap(err, lib.StringTags("start server", "tls"))
+			}
+			break
+		}
+	}
+	// listeners are bound before returning so address errors are reported
+	bound, err := s.listen(listeners)
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("start server", "listen"))
+	}
+	if err = s.serve(listeners, bound); err != nil {
+		for _, l := range bound {
+			l.Close()
+		}
+		return errors.Wrap(err, lib.StringTags("start server", "serve"))
+	}
+	s.URL = urlOf(listeners[0], bound[0])
+	s.addrs = make([]net.Addr, len(bound))
+	for i, l := range bound {
+		s.addrs[i] = l.Addr()
+	}
+	atomic.StoreInt32(&s.ready, 1)
 	return nil
 }
 
-// Stop stops http server
+// Addrs returns addresses of bound listeners in configured order
+func (s *Server) Addrs() []net.Addr {
+	return s.addrs
+}
+
+// Ready reports whether server is started and not stopping
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// Stop stops http server, waiting at most ShutdownTimeout seconds for draining,
+// non positive timeout waits forever
 func (s *Server) Stop() error {
+	ctx := context.Background()
+	if s.Config != nil && s.Config.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx,
+			time.Duration(s.Config.ShutdownTimeout)*time.Second)
+		defer cancel()
+	}
+	return s.StopWithContext(ctx)
+}
+
+// StopWithContext marks server not ready, waits ShutdownDelay seconds then waits
+// for in-flight requests and queued worker pool jobs until ctx is done, then force
+// closes remaining connections and stops every worker pool. Errors of all steps
+// are returned together
+func (s *Server) StopWithContext(ctx context.Context) error {
+	atomic.StoreInt32(&s.ready, 0)
+	if s.S != nil && s.Config != nil && s.Config.ShutdownDelay > 0 {
+		s.Logger.Bg().Info("delaying shutdown", zap.Int("seconds", s.Config.ShutdownDelay))
+		timer := time.NewTimer(time.Duration(s.Config.ShutdownDelay) * time.Second)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	var errs StopErrors
 	if s.S != nil {
 		s.Logger.Bg().Info("shutting down")
-		return s.S.Shutdown(context.Background())
+		if err := s.S.Shutdown(ctx); err != nil {
+			errs = append(errs, errors.Wrap(err, lib.StringTags("stop server", "shutdown")))
+			if err = s.S.Close(); err != nil {
+				errs = append(errs, errors.Wrap(err, lib.StringTags("stop server", "close")))
+			}
+		}
+	}
+	for _, server := range s.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, errors.Wrap(err, lib.StringTags("stop server", "shutdown redirect")))
+			server.Close()
+		}
+	}
+	if s.certReloader != nil {
+		s.certReloader.Close()
+	}
+	// hijacked connections are not tracked by http server
+	if err := s.closeWebSockets(ctx); err != nil {
+		errs = append(errs, errors.Wrap(err, lib.StringTags("stop server", "close websockets")))
 	}
 	for _, workerPool := range s.WorkerPools {
+		if err := workerPool.Drain(ctx); err != nil {
+			errs = append(errs, errors.Wrap(err, lib.StringTags("stop server", "drain worker pool")))
+		}
 		workerPool.StopServer()
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
-//InitHandler initializes route handler
+// InitHandler initializes route handler, requests which match no route pattern
+// fall back to the mux
 func (s *Server) InitHandler() error {
 	s.Mux = http.NewServeMux()
-	s.Handler = http.HandlerFunc(s.Mux.ServeHTTP)
+	s.Router = NewRouter(s.Mux)
+	s.Handler = s.Router
 	return nil
 }
 
@@ -185,6 +299,15 @@ func (s *Server) SetTimeoutOption(read, write int) StartServerOptions {
 	}
 }
 
+// SetShutdownDelayOption set seconds server stays not ready before shutting down, so
+// load balancers stop routing new requests before the listeners close
+func (s *Server) SetShutdownDelayOption(delay int) StartServerOptions {
+	return func() (err error) {
+		s.Config.ShutdownDelay = delay
+		return nil
+	}
+}
+
 // SetTLSOption set http server tls info
 func (s *Server) SetTLSOption(serveTLS bool, certFile, keyFile string) StartServerOptions {
 	return func() (err error) {
@@ -195,14 +318,38 @@ func (s *Server) SetTLSOption(serveTLS bool, certFile, keyFile string) StartServ
 	}
 }
 
+// SetTLSConfigOption set http server serves TLS with certificate reloading and client
+// authentication of config instead of the server config
+func (s *Server) SetTLSConfigOption(config *lib.TLSConfig) StartServerOptions {
+	return func() (err error) {
+		s.Config.ServeTLS = true
+		s.tlsConfig = config
+		return nil
+	}
+}
+
 // SetHandlerOption set http server route handler
 func (s *Server) SetHandlerOption(routes ...ServerRoute) StartServerOptions {
 	return func() (err error) {
 		s.Logger.Bg().Info("setting up handler")
 		for _, route := range routes {
+			// rate limit is prepended last so it runs before the auth requirement
+			if route.Auth != nil {
+				route.Middlewares = append([]Middleware{RequireAuth(route.Auth)}, route.Middlewares...)
+			}
+			if route.RateLimit != nil {
+				middleware, err := RateLimitMiddleware(
+					"route:"+route.Method+":"+route.Path, route.RateLimit, s.Logger)
+				if err != nil {
+					return errors.Wrap(err, lib.StringTags("set handler", route.Name, "rate limit"))
+				}
+				route.Middlewares = append([]Middleware{middleware}, route.Middlewares...)
+			}
 			handler := s.BuildHandler(route)
 			if handler != nil {
-				s.Mux.HandleFunc(route.Path, handler)
+				if err = s.Router.HandleFunc(route.Path, handler); err != nil {
+					return errors.Wrap(err, lib.StringTags("set handler", route.Name))
+				}
 			}
 			s.Logger.Bg().Info("Registered route", zap.String("name", route.Name),
 				zap.String("method", route.Method), zap.String("path", route.Path))
@@ -217,48 +364,163 @@ func (s *Server) BuildHandler(route ServerRoute) (handler http.HandlerFunc) {
 	defer s.routesMux.Unlock()
 	if s.Routes == nil {
 		s.Routes = make(map[string]map[string]http.HandlerFunc)
+		s.definitions = make(map[string]map[string]ServerRoute)
 	}
 	if _, existed := s.Routes[route.Path]; !existed {
 		s.Routes[route.Path] = make(map[string]http.HandlerFunc)
+		s.definitions[route.Path] = make(map[string]ServerRoute)
 		handler = func(w http.ResponseWriter, r *http.Request) {
-			// set response headers
-			w.Header().Set(HeaderAllowOrigin, allowOrigins)
-			w.Header().Set(HeaderAllowCredentials, allowCredentials)
-			w.Header().Set(HeaderExposeHeaders, exposeHeaders)
-
-			// preflight request
-			if r.Method == http.MethodOptions {
-				w.Header().Set(HeaderAllowHeaders, allowHeaders)
-				w.Header().Set(HeaderAllowMethods, allowMethods)
+			// cors and preflight requests are handled by the outermost middleware
+			s.routesMux.RLock()
+			handler, existed := s.Routes[route.Path][r.Method]
+			name := s.definitions[route.Path][r.Method].Name
+			methods := s.routeMethods(route.Path)
+			_, hasOptions := s.Routes[route.Path][http.MethodOptions]
+			s.routesMux.RUnlock()
+
+			if r.Method == http.MethodOptions && !existed {
+				if !hasOptions {
+					methods = append(methods, http.MethodOptions)
+				}
+				w.Header().Set(HeaderAllow, lib.JoinWithComma(methods))
 				SendResponse(w, http.StatusOK, ErrorCodeSuccess, "ok", nil)
 				return
 			}
-			s.routesMux.RLock()
-			handler, existed := s.Routes[route.Path][route.Method]
-			s.routesMux.RUnlock()
 			if !existed {
+				w.Header().Set(HeaderAllow, lib.JoinWithComma(methods))
 				SendResponse(w, http.StatusMethodNotAllowed, ErrorCodeMalformedMethod,
 					"method is not correct for the requested route", nil)
 				return
 			}
+			r, info := withRouteInfo(r)
+			info.name = name
 			handler(w, r)
 		}
 	}
-	s.Routes[route.Path][route.Method] = buildRouteHandler(route.Method, route.Validators, route.Handler)
+	if route.WebSocket != nil {
+		route.Handler = s.webSocketHandler(route.WebSocket)
+	}
+	handle := buildRouteHandler(route.Method, route.Validators, route.Handler)
+	if route.Upload != nil {
+		handle = withUploadConfig(route.Upload, handle)
+	}
+	if route.WriteTimeout != 0 {
+		handle = withWriteTimeout(route.WriteTimeout, handle)
+	}
+	s.Routes[route.Path][route.Method] = Chain(route.Middlewares...)(handle).ServeHTTP
+	s.definitions[route.Path][route.Method] = route
 	return
 }
 
+// contextWithConn puts the connection of requests in their context so routes can
+// override its write deadline
+func contextWithConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, ContextConnKey, conn)
+}
+
+// withWriteTimeout replaces the write deadline the server set for the request,
+// negative timeout removes it. HTTP/2 streams share the connection so they keep
+// the server deadline
+func withWriteTimeout(timeout time.Duration, handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 1 {
+			if conn, ok := r.Context().Value(ContextConnKey).(net.Conn); ok {
+				var deadline time.Time
+				if timeout > 0 {
+					deadline = time.Now().Add(timeout)
+				}
+				conn.SetWriteDeadline(deadline)
+			}
+		}
+		handle(w, r)
+	}
+}
+
+// webSocketHandler upgrades requests and tracks connections until the handler returns
+func (s *Server) webSocketHandler(route *WebSocketRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := UpgradeWebSocket(w, r, route.Config)
+		if err != nil {
+			SendError(w, err)
+			return
+		}
+		s.socketsMux.Lock()
+		if !s.Ready() {
+			s.socketsMux.Unlock()
+			conn.Close(WebSocketCloseGoingAway, "server is shutting down")
+			return
+		}
+		if s.sockets == nil {
+			s.sockets = make(map[*WebSocketConn]struct{})
+		}
+		s.sockets[conn] = struct{}{}
+		s.socketsWG.Add(1)
+		s.socketsMux.Unlock()
+		defer func() {
+			s.socketsMux.Lock()
+			delete(s.sockets, conn)
+			s.socketsMux.Unlock()
+			s.socketsWG.Done()
+		}()
+		if err = route.Handler(conn); err != nil {
+			if _, ok := err.(*WebSocketCloseError); !ok && err != ErrWebSocketClosed {
+				s.Logger.For(r.Context()).Error("websocket handler", zap.Error(err))
+				conn.Close(WebSocketCloseInternalError, "internal server error")
+				return
+			}
+		}
+		conn.Close(WebSocketCloseNormal, "")
+	}
+}
+
+// closeWebSockets sends going away to open websockets and waits until their handlers
+// return or ctx is done
+func (s *Server) closeWebSockets(ctx context.Context) error {
+	s.socketsMux.Lock()
+	open := len(s.sockets)
+	for conn := range s.sockets {
+		go conn.Close(WebSocketCloseGoingAway, "server is shutting down")
+	}
+	s.socketsMux.Unlock()
+	if open == 0 {
+		return nil
+	}
+	finished := make(chan struct{})
+	go func() {
+		s.socketsWG.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// routeMethods returns sorted methods registered for the path, caller must hold routesMux
+func (s *Server) routeMethods(path string) []string {
+	methods := make([]string, 0, len(s.Routes[path])+1)
+	for method := range s.Routes[path] {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
 // SetMiddlewareTracerOption set http server middleware type tracer
 func (s *Server) SetMiddlewareTracerOption(tracer *trace.Client) StartServerOptions {
 	return func() (err error) {
 		if tracer == nil {
 			return errors.New("option SetTracerOption must be set first")
 		}
-		s.Handler = &TracerMiddleWare{
-			Handler: s.Handler,
-			Client:  tracer,
-			Logger:  s.Logger,
-		}
+		s.Use(func(handler http.Handler) http.Handler {
+			return &TracerMiddleWare{
+				Handler: handler,
+				Client:  tracer,
+				Logger:  s.Logger,
+			}
+		})
 		return nil
 	}
 }
@@ -276,11 +538,14 @@ func (s *Server) SetMiddlewareWorkerPoolOption(maxWorkers int) StartServerOption
 		}
 
 		s.WorkerPools = append(s.WorkerPools, workerPool)
-		s.Handler = &WorkerPoolMiddleware{
-			Handler: s.Handler,
-			Pool:    workerPool,
-			Timeout: time.Duration(s.Config.ReadTimeout) * time.Second,
-		}
+		s.Use(func(handler http.Handler) http.Handler {
+			return &WorkerPoolMiddleware{
+				Handler: handler,
+				Pool:    workerPool,
+				Logger:  s.Logger,
+				Timeout: time.Duration(s.Config.ReadTimeout) * time.Second,
+			}
+		})
 		return nil
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -86,3 +87,102 @@ func TestServer(t *testing.T) {
 	require.Equal(t, field3, d.Field3)
 	require.Equal(t, fieldRequire, d.FieldRequire)
 }
+
+func TestServerStopWithContext(t *testing.T) {
+	t.Run("error drain deadline", func(t *testing.T) {
+		server, err := CreateServer()
+		require.Nil(t, err)
+		require.NotNil(t, server)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		asyncHandler, err := server.SetupWorkerPoolHandler(1, func(_ http.ResponseWriter, _ *http.Request) {
+			<-release
+		})
+		require.Nil(t, err)
+		err = server.Start(server.SetHostPortOption("localhost", 18004),
+			server.SetHandlerOption(
+				ServerRoute{
+					Name:   "slow",
+					Method: http.MethodGet,
+					Path:   "/slow",
+					Handler: func(w http.ResponseWriter, _ *http.Request) {
+						close(started)
+						<-release
+					},
+				},
+				ServerRoute{
+					Name:    "async",
+					Method:  http.MethodGet,
+					Path:    "/async",
+					Handler: asyncHandler,
+				},
+			))
+		require.Nil(t, err)
+		require.True(t, server.Ready())
+		hc := http.Client{}
+		resp, err := hc.Get(server.URL + "/async")
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		go hc.Get(server.URL + "/slow")
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err = server.StopWithContext(ctx)
+		require.Error(t, err)
+		stopErrors, ok := err.(StopErrors)
+		require.True(t, ok)
+		// shutdown deadline and pending async job
+		require.Len(t, stopErrors, 2)
+		require.False(t, server.Ready())
+		_, err = hc.Get(server.URL + "/slow")
+		require.Error(t, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		server, err := CreateServer()
+		require.Nil(t, err)
+		require.NotNil(t, server)
+		err = server.Start(server.SetHostPortOption("localhost", 18005),
+			server.SetMiddlewareWorkerPoolOption(2))
+		require.Nil(t, err)
+		require.True(t, server.Ready())
+		require.Nil(t, server.Stop())
+		require.False(t, server.Ready())
+		require.Zero(t, server.WorkerPools[0].ActiveJobs())
+	})
+
+	t.Run("shutdown delay", func(t *testing.T) {
+		server, err := CreateServer()
+		require.Nil(t, err)
+		require.NotNil(t, server)
+		err = server.Start(server.SetHostPortOption("127.0.0.1", 0),
+			server.SetShutdownDelayOption(1),
+			server.SetHealthCheckOption(nil, nil))
+		require.Nil(t, err)
+		hc := http.Client{}
+		resp, err := hc.Get(server.URL + ReadinessPath)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		start := time.Now()
+		stopped := make(chan error)
+		go func() {
+			stopped <- server.Stop()
+		}()
+		for server.Ready() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		// still serving while not ready
+		resp, err = hc.Get(server.URL + ReadinessPath)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.Nil(t, <-stopped)
+		require.True(t, time.Since(start) >= time.Second)
+		_, err = hc.Get(server.URL + ReadinessPath)
+		require.Error(t, err)
+	})
+}
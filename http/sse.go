@@ -0,0 +1,318 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hauxe/gom/broadcast"
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// ErrStreamClosed is returned when writing to a closed event stream or a stream
+// whose client disconnected
+var ErrStreamClosed = errors.New("event stream is closed")
+
+// removes line breaks which would end a field early
+var sseFieldReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+// SSEConfig defines server-sent events stream behavior
+type SSEConfig struct {
+	// Retry hints how long clients wait before reconnecting, zero sends no hint
+	Retry time.Duration
+	// Heartbeat is the interval of comments keeping an idle connection open, zero
+	// disables heartbeats
+	Heartbeat time.Duration
+	// Replay returns events missed since the Last-Event-ID sent by a reconnecting
+	// client, they are sent before any other event
+	Replay func(lastEventID string) ([]Event, error)
+}
+
+// DefaultSSEConfig returns config with 3 seconds retry and 15 seconds heartbeat
+func DefaultSSEConfig() *SSEConfig {
+	return &SSEConfig{
+		Retry:     3 * time.Second,
+		Heartbeat: 15 * time.Second,
+	}
+}
+
+// Event defines a server-sent event, Data other than string and []byte is sent as
+// JSON
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// EventStream writes server-sent events to a client
+type EventStream struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+	done        <-chan struct{}
+	closed      chan struct{}
+	isClosed    bool
+	mux         sync.Mutex
+}
+
+// NewEventStream writes event stream headers and starts heartbeats, the stream must
+// be closed before the handler returns. Nil config uses DefaultSSEConfig
+func NewEventStream(w http.ResponseWriter, r *http.Request, config *SSEConfig) (*EventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New(lib.StringTags("new event stream", "response writer does not support flushing"))
+	}
+	if config == nil {
+		config = DefaultSSEConfig()
+	}
+	lastEventID := r.Header.Get(HeaderLastEventID)
+	var missed []Event
+	if lastEventID != "" && config.Replay != nil {
+		// replayed before headers are written so failure can be sent as error
+		var err error
+		if missed, err = config.Replay(lastEventID); err != nil {
+			return nil, errors.Wrap(err, lib.StringTags("new event stream", "replay"))
+		}
+	}
+	header := w.Header()
+	header.Set(HeaderContentType, ContentTypeEventStream)
+	header.Set(HeaderCacheControl, "no-cache")
+	// disables response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	s := &EventStream{
+		w:           w,
+		flusher:     flusher,
+		lastEventID: lastEventID,
+		done:        r.Context().Done(),
+		closed:      make(chan struct{}),
+	}
+	if config.Retry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", config.Retry/time.Millisecond)
+	}
+	for _, event := range missed {
+		if err := s.writeEvent(event); err != nil {
+			return nil, errors.Wrap(err, lib.StringTags("new event stream", "replay"))
+		}
+	}
+	flusher.Flush()
+	if config.Heartbeat > 0 {
+		go s.heartbeat(config.Heartbeat)
+	}
+	return s, nil
+}
+
+// LastEventID returns the id of the last event received by a reconnecting client
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns channel closed when the client disconnects
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes event and flushes it to the client
+func (s *EventStream) Send(event Event) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.isClosed || s.disconnected() {
+		return ErrStreamClosed
+	}
+	if err := s.writeEvent(event); err != nil {
+		return errors.Wrap(err, lib.StringTags("send event", event.ID))
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// Close stops heartbeats, the stream can no longer be written
+func (s *EventStream) Close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.isClosed {
+		s.isClosed = true
+		close(s.closed)
+	}
+}
+
+// Subscribe sends every value written to broadcaster until the client disconnects,
+// the stream or the broadcaster is closed. Values are converted by toEvent, nil
+// sends Event values as is and other values as event data
+func (s *EventStream) Subscribe(b *broadcast.Broadcaster,
+	toEvent func(interface{}) (Event, error)) error {
+	receiver, err := b.Listen()
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("subscribe", "listen"))
+	}
+	values := make(chan interface{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(values)
+		for {
+			// read only fails when broadcaster is closed
+			v, err := receiver.Read()
+			if err != nil {
+				return
+			}
+			select {
+			case values <- v:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case v, ok := <-values:
+			if !ok {
+				return nil
+			}
+			event, err := eventOf(v, toEvent)
+			if err != nil {
+				return errors.Wrap(err, lib.StringTags("subscribe", "convert value"))
+			}
+			if err = s.Send(event); err != nil {
+				if err == ErrStreamClosed {
+					return nil
+				}
+				return err
+			}
+		case <-s.done:
+			return nil
+		case <-s.closed:
+			return nil
+		}
+	}
+}
+
+func eventOf(v interface{}, toEvent func(interface{}) (Event, error)) (Event, error) {
+	if toEvent != nil {
+		return toEvent(v)
+	}
+	switch event := v.(type) {
+	case Event:
+		return event, nil
+	case *Event:
+		return *event, nil
+	}
+	return Event{Data: v}, nil
+}
+
+func (s *EventStream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mux.Lock()
+			if !s.isClosed {
+				io.WriteString(s.w, ": heartbeat\n\n")
+				s.flusher.Flush()
+			}
+			s.mux.Unlock()
+		case <-s.done:
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *EventStream) disconnected() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeEvent writes event fields, each line of data is sent as a data field
+func (s *EventStream) writeEvent(event Event) error {
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		body, err := json.Marshal(v)
+		if err != nil {
+			return errors.Wrap(err, "marshal data")
+		}
+		data = string(body)
+	}
+	var buf strings.Builder
+	if event.ID != "" {
+		buf.WriteString("id: " + sseFieldReplacer.Replace(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + sseFieldReplacer.Replace(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", event.Retry/time.Millisecond)
+	}
+	data = strings.Replace(strings.Replace(data, "\r\n", "\n", -1), "\r", "\n", -1)
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, err := io.WriteString(s.w, buf.String())
+	return err
+}
+
+// SSEHandler creates handler streaming events by stream, the stream is closed when
+// stream returns and its error is sent as event error
+func SSEHandler(config *SSEConfig, stream func(*EventStream) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := NewEventStream(w, r, config)
+		if err != nil {
+			SendError(w, err)
+			return
+		}
+		defer s.Close()
+		if err = stream(s); err != nil && err != ErrStreamClosed {
+			s.Send(Event{Event: "error", Data: err.Error()})
+		}
+	}
+}
+
+// BroadcastHandler creates handler pushing every value written to b to the client,
+// see EventStream.Subscribe
+func BroadcastHandler(b *broadcast.Broadcaster, config *SSEConfig,
+	toEvent func(interface{}) (Event, error)) http.HandlerFunc {
+	return SSEHandler(config, func(s *EventStream) error {
+		return s.Subscribe(b, toEvent)
+	})
+}
+
+// Stream flushes what step writes to the client after each call until step returns
+// false or error, or the client disconnects
+func Stream(w http.ResponseWriter, r *http.Request, step func(w io.Writer) (bool, error)) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New(lib.StringTags("stream", "response writer does not support flushing"))
+	}
+	done := r.Context().Done()
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		more, err := step(w)
+		flusher.Flush()
+		if err != nil || !more {
+			return err
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hauxe/gom/broadcast"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStream(t *testing.T) {
+	t.Parallel()
+	config := &SSEConfig{
+		Retry: 2 * time.Second,
+		Replay: func(lastEventID string) ([]Event, error) {
+			require.Equal(t, "2", lastEventID)
+			return []Event{{ID: "3", Data: "missed"}}, nil
+		},
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set(HeaderLastEventID, "2")
+	SSEHandler(config, func(s *EventStream) error {
+		require.Equal(t, "2", s.LastEventID())
+		require.Nil(t, s.Send(Event{ID: "4\n", Event: "update", Data: "line 1\r\nline 2"}))
+		require.Nil(t, s.Send(Event{Data: map[string]int{"count": 1}, Retry: time.Second}))
+		return errors.New("boom")
+	})(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, ContentTypeEventStream, w.Header().Get(HeaderContentType))
+	require.Equal(t, "no-cache", w.Header().Get(HeaderCacheControl))
+	require.Equal(t, "retry: 2000\n\n"+
+		"id: 3\ndata: missed\n\n"+
+		"id: 4\nevent: update\ndata: line 1\ndata: line 2\n\n"+
+		"retry: 1000\ndata: {\"count\":1}\n\n"+
+		"event: error\ndata: boom\n\n", w.Body.String())
+
+	t.Run("replay error", func(t *testing.T) {
+		t.Parallel()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/events", nil)
+		r.Header.Set(HeaderLastEventID, "1")
+		SSEHandler(&SSEConfig{Replay: func(string) ([]Event, error) {
+			return nil, errors.New("no history")
+		}}, func(s *EventStream) error {
+			t.Fatal("stream must not start")
+			return nil
+		})(w, r)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+		s, err := NewEventStream(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/events", nil), nil)
+		require.Nil(t, err)
+		s.Close()
+		s.Close()
+		require.Equal(t, ErrStreamClosed, s.Send(Event{Data: "late"}))
+	})
+}
+
+func TestBroadcastHandler(t *testing.T) {
+	b := broadcast.NewBroadcaster()
+	defer b.Close()
+	done := make(chan struct{})
+	handler := BroadcastHandler(b, &SSEConfig{Heartbeat: 100 * time.Millisecond}, nil)
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetHostPortOption("localhost", 18012),
+		server.SetTimeoutOption(32, 1),
+		server.SetHandlerOption(ServerRoute{
+			Name:         "events",
+			Method:       http.MethodGet,
+			Path:         "/events",
+			WriteTimeout: -1,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				handler(w, r)
+				close(done)
+			},
+		}))
+	require.Nil(t, err)
+	defer server.Stop()
+
+	resp, err := http.Get(server.URL + "/events")
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, ContentTypeEventStream, resp.Header.Get(HeaderContentType))
+	// written after the server write timeout expired
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		b.Write(Event{ID: "1", Event: "greeting", Data: "hello"})
+	}()
+	reader := bufio.NewReader(resp.Body)
+	heartbeats := 0
+	var event []string
+	for len(event) < 3 {
+		line, err := reader.ReadString('\n')
+		require.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == ": heartbeat":
+			heartbeats++
+		case line != "" && !strings.HasPrefix(line, "retry:"):
+			event = append(event, line)
+		}
+	}
+	require.Equal(t, []string{"id: 1", "event: greeting", "data: hello"}, event)
+	require.True(t, heartbeats > 0)
+	// handler returns once client disconnects
+	resp.Body.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler is still streaming to disconnected client")
+	}
+}
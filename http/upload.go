@@ -0,0 +1,161 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// errBodyTooLarge is the message of http.MaxBytesReader errors
+const errBodyTooLarge = "http: request body too large"
+
+// UploadConfig defines multipart upload limits of a route
+type UploadConfig struct {
+	// MaxMemory bytes of files are kept in memory, the rest spills to temp files
+	// which are removed after the request
+	MaxMemory int64
+	// MaxSize limits the request body, non positive is unlimited
+	MaxSize int64
+	// MaxFileSize limits each uploaded file, non positive is unlimited
+	MaxFileSize int64
+	// Stream leaves the multipart body to be read part by part with StreamMultipart,
+	// ParseParameters only decodes the query
+	Stream bool
+}
+
+// DefaultUploadConfig returns config keeping 32MB in memory and limiting body to 64MB
+func DefaultUploadConfig() *UploadConfig {
+	return &UploadConfig{
+		MaxMemory: 32 << 20,
+		MaxSize:   64 << 20,
+	}
+}
+
+// UploadedFile defines a file part of multipart form, struct fields of type
+// *UploadedFile or []*UploadedFile are filled by ParseParameters
+type UploadedFile struct {
+	*multipart.FileHeader
+}
+
+// ContentType returns content type declared by the client
+func (f *UploadedFile) ContentType() string {
+	return f.Header.Get(HeaderContentType)
+}
+
+var (
+	uploadedFileType      = reflect.TypeOf(&UploadedFile{})
+	uploadedFileSliceType = reflect.TypeOf([]*UploadedFile{})
+)
+
+// withUploadConfig injects the route upload config to request context
+func withUploadConfig(config *UploadConfig, handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r.WithContext(context.WithValue(r.Context(), ContextUploadKey, config)))
+	}
+}
+
+func uploadConfigOf(r *http.Request) *UploadConfig {
+	if config, ok := r.Context().Value(ContextUploadKey).(*UploadConfig); ok {
+		return config
+	}
+	return DefaultUploadConfig()
+}
+
+// limitBody limits request body to MaxSize of the route upload config
+func limitBody(r *http.Request, config *UploadConfig) {
+	if config.MaxSize > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, config.MaxSize)
+	}
+}
+
+// parseMultipartForm parses multipart body unless the route streams it
+func parseMultipartForm(r *http.Request) error {
+	config := uploadConfigOf(r)
+	if config.Stream {
+		return nil
+	}
+	limitBody(r, config)
+	if err := r.ParseMultipartForm(config.MaxMemory); err != nil {
+		if err.Error() == errBodyTooLarge {
+			return RequestTooLargeError{err}
+		}
+		return BadRequestError{err}
+	}
+	return nil
+}
+
+// decodeUploadedFiles fills uploaded file fields of dst named by their schema tag
+func decodeUploadedFiles(r *http.Request, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if r.MultipartForm == nil || rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	maxFileSize := uploadConfigOf(r).MaxFileSize
+	rv = rv.Elem()
+	fieldErrors := FieldErrors{}
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if field.Type != uploadedFileType && field.Type != uploadedFileSliceType {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("schema"), ",")[0]
+		if name == "" {
+			name = fieldName(field)
+		}
+		headers := r.MultipartForm.File[name]
+		if len(headers) == 0 {
+			continue
+		}
+		files := make([]*UploadedFile, len(headers))
+		for j, header := range headers {
+			if maxFileSize > 0 && header.Size > maxFileSize {
+				fieldErrors[name] = fmt.Sprintf("size must be at most %d bytes", maxFileSize)
+			}
+			files[j] = &UploadedFile{header}
+		}
+		if field.Type == uploadedFileType {
+			rv.Field(i).Set(reflect.ValueOf(files[0]))
+		} else {
+			rv.Field(i).Set(reflect.ValueOf(files))
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return ValidationError{fieldErrors}
+	}
+	return nil
+}
+
+// StreamMultipart reads multipart body part by part without buffering, it is used by
+// routes whose upload config streams. The body is limited by MaxSize, errors of
+// handle are returned as is
+func StreamMultipart(r *http.Request, handle func(part *multipart.Part) error) error {
+	limitBody(r, uploadConfigOf(r))
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return BadRequestError{err}
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if err.Error() == errBodyTooLarge {
+				return RequestTooLargeError{err}
+			}
+			return BadRequestError{err}
+		}
+		err = handle(part)
+		part.Close()
+		if err != nil {
+			if err.Error() == errBodyTooLarge {
+				return RequestTooLargeError{err}
+			}
+			return err
+		}
+	}
+}
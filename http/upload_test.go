@@ -0,0 +1,184 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type uploadRequest struct {
+	Name   string          `schema:"name"`
+	Page   int             `schema:"page"`
+	Avatar *UploadedFile   `schema:"avatar" validate:"required"`
+	Docs   []*UploadedFile `schema:"docs"`
+}
+
+func multipartRequest(t *testing.T, target string, fields map[string]string,
+	files map[string][]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		require.Nil(t, writer.WriteField(key, value))
+	}
+	for key, contents := range files {
+		for i, content := range contents {
+			part, err := writer.CreateFormFile(key, fmt.Sprintf("%s%d.txt", key, i))
+			require.Nil(t, err)
+			_, err = part.Write([]byte(content))
+			require.Nil(t, err)
+		}
+	}
+	require.Nil(t, writer.Close())
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	r.Header.Set(HeaderContentType, writer.FormDataContentType())
+	return r
+}
+
+func TestParseParametersMultipart(t *testing.T) {
+	t.Parallel()
+	config := &UploadConfig{MaxMemory: 4, MaxSize: 1024, MaxFileSize: 16}
+	var parsed uploadRequest
+	var avatar []byte
+	handler := withUploadConfig(config, buildRouteHandler(http.MethodPost, nil,
+		func(w http.ResponseWriter, r *http.Request) {
+			parsed = uploadRequest{}
+			if err := ParseParameters(r, &parsed); err != nil {
+				SendError(w, err)
+				return
+			}
+			file, err := parsed.Avatar.Open()
+			require.Nil(t, err)
+			defer file.Close()
+			avatar, err = ioutil.ReadAll(file)
+			require.Nil(t, err)
+			SendResponse(w, http.StatusOK, ErrorCodeSuccess, "ok", nil)
+		}))
+
+	r := multipartRequest(t, "/?page=2", map[string]string{"name": "gom"}, map[string][]string{
+		"avatar": {"avatar content"},
+		"docs":   {"doc 1", "doc 2"},
+	})
+	w := httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "gom", parsed.Name)
+	require.Equal(t, 2, parsed.Page)
+	require.NotNil(t, parsed.Avatar)
+	require.Equal(t, "avatar0.txt", parsed.Avatar.Filename)
+	require.Equal(t, int64(14), parsed.Avatar.Size)
+	require.Equal(t, "application/octet-stream", parsed.Avatar.ContentType())
+	require.Equal(t, "avatar content", string(avatar))
+	require.Len(t, parsed.Docs, 2)
+	require.Equal(t, "docs1.txt", parsed.Docs[1].Filename)
+	// larger than MaxMemory so it is spilled to a temp file removed after the request
+	_, err := parsed.Avatar.Open()
+	require.Error(t, err)
+
+	r = multipartRequest(t, "/", map[string]string{"name": "gom"}, nil)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	dest := ServerResponse{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest))
+	require.Equal(t, ErrorCodeValidationFailed, dest.ErrorCode)
+	require.Equal(t, map[string]interface{}{"avatar": "is required"}, dest.Data.Error)
+
+	r = multipartRequest(t, "/", nil, map[string][]string{"avatar": {strings.Repeat("a", 17)}})
+	w = httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	dest = ServerResponse{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest))
+	require.Equal(t, map[string]interface{}{"avatar": "size must be at most 16 bytes"},
+		dest.Data.Error)
+
+	r = multipartRequest(t, "/", nil, map[string][]string{"avatar": {strings.Repeat("a", 2048)}})
+	w = httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	dest = ServerResponse{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest))
+	require.Equal(t, ErrorCodeRequestTooLarge, dest.ErrorCode)
+}
+
+func TestParseParametersMediaType(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		ContentType string
+		Body        string
+	}{
+		{"application/json; charset=utf-8", `{"field1":"a","field_require":true}`},
+		{"Application/JSON", `{"field1":"a","field_require":true}`},
+		{"application/x-www-form-urlencoded; charset=utf-8", "field1=a&field_require=true"},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.Body))
+		r.Header.Set(HeaderContentType, tc.ContentType)
+		dest := data{}
+		require.Nil(t, ParseParameters(r, &dest), tc.ContentType)
+		require.Equal(t, "a", dest.Field1, tc.ContentType)
+		require.True(t, dest.FieldRequire, tc.ContentType)
+	}
+}
+
+func TestStreamMultipart(t *testing.T) {
+	t.Parallel()
+	config := &UploadConfig{MaxSize: 1024, Stream: true}
+	handler := withUploadConfig(config, func(w http.ResponseWriter, r *http.Request) {
+		dest := struct {
+			Page int `schema:"page"`
+		}{}
+		if err := ParseParameters(r, &dest); err != nil {
+			SendError(w, err)
+			return
+		}
+		parts := []string{}
+		err := StreamMultipart(r, func(part *multipart.Part) error {
+			content, err := ioutil.ReadAll(part)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, part.FormName()+"="+string(content))
+			return nil
+		})
+		if err != nil {
+			SendError(w, err)
+			return
+		}
+		SendResponse(w, http.StatusOK, ErrorCodeSuccess, strings.Join(parts, ","),
+			map[string]interface{}{"success": dest.Page})
+	})
+
+	// body is streamed from a real connection after ParseParameters returns
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	send := func(r *http.Request) *http.Response {
+		request, err := http.NewRequest(http.MethodPost, server.URL+r.URL.RequestURI(), r.Body)
+		require.Nil(t, err)
+		request.Header.Set(HeaderContentType, r.Header.Get(HeaderContentType))
+		resp, err := http.DefaultClient.Do(request)
+		require.Nil(t, err)
+		return resp
+	}
+
+	resp := send(multipartRequest(t, "/?page=3", map[string]string{"name": "gom"},
+		map[string][]string{"file": {"content"}}))
+	defer resp.Body.Close()
+	dest := ServerResponse{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&dest))
+	require.Equal(t, http.StatusOK, resp.StatusCode, dest.ErrorMessage)
+	require.Equal(t, "name=gom,file=content", dest.ErrorMessage)
+	require.Equal(t, float64(3), dest.Data.Success)
+
+	resp = send(multipartRequest(t, "/", nil, map[string][]string{"file": {strings.Repeat("a", 2048)}}))
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
@@ -0,0 +1,276 @@
+package http
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const validateTag = "validate"
+
+// FieldErrors maps invalid field names to their failure messages
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	fields := make([]string, 0, len(fe))
+	for field := range fe {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field + " " + fe[field]
+	}
+	return strings.Join(messages, "; ")
+}
+
+// compiled regex rules
+var regexps sync.Map
+
+// ValidateStruct validates fields of struct v by their `validate` tag, rules are
+// separated by comma:
+//
+//	required    value is not zero
+//	omitempty   skips other rules when value is zero
+//	min=n max=n number value, or length of string, slice and map, is in bound
+//	len=n       length of string, slice and map is n
+//	email       string is an email address
+//	oneof=a b   value is one of the space separated values
+//	regex=p     string matches pattern p which must not contain comma
+//
+// Unknown rules, e.g. of other validators sharing the tag, are skipped and so are the
+// element rules following dive. Fields are named by their json tag and nested
+// structs are validated with the parent name as prefix. Invalid fields are returned
+// as FieldErrors, a malformed rule is returned as error
+func ValidateStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	fieldErrors := FieldErrors{}
+	if err := validateStruct(rv, "", fieldErrors); err != nil {
+		return err
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, fieldErrors FieldErrors) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" && value.Kind() == reflect.Struct {
+			// fields of embedded struct are promoted even if its type is unexported
+			if err := validateStruct(value, prefix, fieldErrors); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := fieldName(field)
+		if name == "" {
+			continue
+		}
+		name = prefix + name
+		if tag := field.Tag.Get(validateTag); tag != "" && tag != "-" {
+			message, err := validateValue(value, tag)
+			if err != nil {
+				return errors.Wrap(err, name)
+			}
+			if message != "" {
+				fieldErrors[name] = message
+				continue
+			}
+		}
+		if err := validateNested(value, name, fieldErrors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateNested(value reflect.Value, name string, fieldErrors FieldErrors) error {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		return validateStruct(value, name+".", fieldErrors)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := validateNested(value.Index(i), fmt.Sprintf("%s[%d]", name, i),
+				fieldErrors); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns json name of the field, empty when it is ignored
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "schema", pathTag} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validateValue returns the message of the first failed rule
+func validateValue(value reflect.Value, tag string) (string, error) {
+	rules := strings.Split(tag, ",")
+	zero := isZero(value)
+	for _, rule := range rules {
+		if rule == "required" && zero {
+			return "is required", nil
+		}
+		if rule == "omitempty" && zero {
+			return "", nil
+		}
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", nil
+		}
+		value = value.Elem()
+	}
+	for _, rule := range rules {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		if name == "dive" {
+			// following rules apply to the elements
+			break
+		}
+		message, err := checkRule(value, name, param)
+		if err != nil || message != "" {
+			return message, err
+		}
+	}
+	return "", nil
+}
+
+func checkRule(value reflect.Value, name, param string) (string, error) {
+	switch name {
+	case "", "required", "omitempty":
+		return "", nil
+	case "min", "max", "len":
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", errors.Errorf("invalid %s rule %q", name, param)
+		}
+		size, isLength, ok := sizeOf(value)
+		if !ok {
+			return "", errors.Errorf("%s rule does not support %s", name, value.Kind())
+		}
+		subject := "must be"
+		if isLength {
+			subject = "length must be"
+		}
+		switch {
+		case name == "min" && size < bound:
+			return fmt.Sprintf("%s at least %s", subject, param), nil
+		case name == "max" && size > bound:
+			return fmt.Sprintf("%s at most %s", subject, param), nil
+		case name == "len" && size != bound:
+			return fmt.Sprintf("length must be %s", param), nil
+		}
+	case "email":
+		if value.Kind() != reflect.String {
+			return "", errors.Errorf("email rule does not support %s", value.Kind())
+		}
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "must be a valid email", nil
+		}
+	case "oneof":
+		actual := fmt.Sprint(value.Interface())
+		options := strings.Fields(param)
+		for _, option := range options {
+			if option == actual {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", strings.Join(options, " ")), nil
+	case "regex":
+		if value.Kind() != reflect.String {
+			return "", errors.Errorf("regex rule does not support %s", value.Kind())
+		}
+		re, err := compileRegex(param)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(value.String()) {
+			return fmt.Sprintf("must match %s", param), nil
+		}
+	}
+	return "", nil
+}
+
+// sizeOf returns number value or length of value
+func sizeOf(value reflect.Value) (size float64, isLength bool, ok bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid regex rule")
+	}
+	regexps.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateBase struct {
+	ID int64 `json:"id" validate:"min=1"`
+}
+
+type validateRequest struct {
+	validateBase
+	Name     string            `json:"name" validate:"required,min=2,max=5"`
+	Email    string            `json:"email" validate:"omitempty,email"`
+	Role     string            `json:"role" validate:"oneof=admin user"`
+	Code     string            `json:"code" validate:"omitempty,regex=^[A-Z]{3}$"`
+	Tags     []string          `json:"tags" validate:"max=2"`
+	Age      *int              `json:"age" validate:"omitempty,min=18"`
+	PIN      string            `schema:"pin" json:"-" validate:"omitempty,len=4"`
+	Address  *validateAddress  `json:"address"`
+	Contacts []validateAddress `json:"contacts"`
+	internal string
+}
+
+func TestValidateStruct(t *testing.T) {
+	t.Parallel()
+	age := 17
+	valid := validateRequest{
+		validateBase: validateBase{ID: 1},
+		Name:         "gom",
+		Role:         "user",
+	}
+	require.Nil(t, ValidateStruct(&valid))
+	require.Nil(t, ValidateStruct(nil))
+	require.Nil(t, ValidateStruct("not struct"))
+
+	invalid := validateRequest{
+		Name:     "g",
+		Email:    "Gom <gom@example.com>",
+		Role:     "root",
+		Code:     "abc",
+		Tags:     []string{"a", "b", "c"},
+		Age:      &age,
+		Address:  &validateAddress{},
+		Contacts: []validateAddress{{City: "a"}, {}},
+	}
+	err := ValidateStruct(&invalid)
+	require.Equal(t, FieldErrors{
+		"id":               "must be at least 1",
+		"name":             "length must be at least 2",
+		"email":            "must be a valid email",
+		"role":             "must be one of [admin user]",
+		"code":             "must match ^[A-Z]{3}$",
+		"tags":             "length must be at most 2",
+		"age":              "must be at least 18",
+		"address.city":     "is required",
+		"contacts[1].city": "is required",
+	}, err)
+
+	err = ValidateStruct(&validateRequest{validateBase: validateBase{ID: 1}, Role: "user",
+		Name: "gomgomgom", Email: "gom@example.com", Code: "ABC"})
+	require.Equal(t, FieldErrors{"name": "length must be at most 5"}, err)
+	require.Equal(t, "name length must be at most 5", err.Error())
+
+	// malformed rules are not field errors
+	_, ok := ValidateStruct(&struct {
+		Name string `validate:"min=x"`
+	}{}).(FieldErrors)
+	require.False(t, ok)
+	_, ok = ValidateStruct(&struct {
+		Name bool `validate:"email"`
+	}{}).(FieldErrors)
+	require.False(t, ok)
+
+	// rules of other validators are skipped
+	require.Nil(t, ValidateStruct(&struct {
+		ID    string   `validate:"required,uuid"`
+		Count int      `validate:"gte=1"`
+		Tags  []string `validate:"max=2,dive,min=5"`
+	}{ID: "id", Tags: []string{"a"}}))
+	err = ValidateStruct(&struct {
+		Tags []string `validate:"max=1,dive,min=5"`
+	}{Tags: []string{"a", "b"}})
+	require.Equal(t, FieldErrors{"Tags": "length must be at most 1"}, err)
+}
+
+func TestParseParametersValidation(t *testing.T) {
+	t.Parallel()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		dst := validateRequest{}
+		if err := ParseParameters(r, &dst); err != nil {
+			SendError(w, err)
+			return
+		}
+		SendResponse(w, http.StatusOK, ErrorCodeSuccess, "ok", nil)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(`{"id":1,"name":"g","role":"user","email":"invalid"}`))
+	r.Header.Set(HeaderContentType, ContentTypeJSON)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	dest := ServerResponse{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dest))
+	require.Equal(t, ErrorCodeValidationFailed, dest.ErrorCode)
+	require.Equal(t, "email must be a valid email; name length must be at least 2", dest.ErrorMessage)
+	require.Equal(t, map[string]interface{}{
+		"email": "must be a valid email",
+		"name":  "length must be at least 2",
+	}, dest.Data.Error)
+
+	r = httptest.NewRequest(http.MethodGet, "/?id=2&name=gom&role=admin&pin=1234", nil)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// requests tagged for other validators still parse
+	playground := struct {
+		ID   string   `schema:"id" validate:"required,uuid"`
+		Page int      `schema:"page" validate:"omitempty,gte=1"`
+		Tags []string `schema:"tags" validate:"dive,alphanum"`
+	}{}
+	r = httptest.NewRequest(http.MethodGet, "/?id=abc&page=2&tags=a", nil)
+	require.Nil(t, ParseParameters(r, &playground))
+	require.Equal(t, "abc", playground.ID)
+}
@@ -0,0 +1,536 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// websocket message types
+const (
+	WebSocketTextMessage   = 1
+	WebSocketBinaryMessage = 2
+)
+
+// websocket close codes
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseGoingAway       = 1001
+	WebSocketCloseProtocolError   = 1002
+	WebSocketCloseNoStatus        = 1005
+	WebSocketCloseInvalidPayload  = 1007
+	WebSocketClosePolicyViolation = 1008
+	WebSocketCloseMessageTooBig   = 1009
+	WebSocketCloseInternalError   = 1011
+)
+
+// frame opcodes
+const (
+	opContinuation = 0
+	opText         = 1
+	opBinary       = 2
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// errors of websocket connections
+var (
+	ErrWebSocketClosed    = errors.New("websocket connection is closed")
+	ErrWebSocketQueueFull = errors.New("websocket send queue is full")
+	errWebSocketProtocol  = errors.New("websocket protocol error")
+	errWebSocketTooBig    = errors.New("websocket message is too big")
+)
+
+// WebSocketCloseError is returned by ReadMessage when the peer closes the connection
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed %d %s", e.Code, e.Reason)
+}
+
+// WebSocketConfig defines websocket connection behavior
+type WebSocketConfig struct {
+	// ReadTimeout closes connection which sends nothing, pongs included, for this long
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing each frame
+	WriteTimeout time.Duration
+	// PingInterval must be less than ReadTimeout, zero disables pings
+	PingInterval time.Duration
+	// MaxMessageSize in bytes, larger messages close the connection, zero uses the
+	// default 1MB
+	MaxMessageSize int
+	// SendQueueSize is the number of messages queued per connection, zero uses the
+	// default 64
+	SendQueueSize int
+	// Subprotocols supported in preference order
+	Subprotocols []string
+	// CheckOrigin allows the upgrade, nil allows requests without Origin header or
+	// from the same host
+	CheckOrigin func(r *http.Request) bool
+}
+
+// DefaultWebSocketConfig returns config pinging every 54 seconds, closing after 60
+// seconds of silence and reading messages up to 1MB
+func DefaultWebSocketConfig() *WebSocketConfig {
+	return &WebSocketConfig{
+		ReadTimeout:    60 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		PingInterval:   54 * time.Second,
+		MaxMessageSize: 1 << 20,
+		SendQueueSize:  64,
+	}
+}
+
+// webSocketConfigOf returns copy of config with zero message size and queue size
+// set to their defaults, so clients cannot send unbounded messages
+func webSocketConfigOf(config *WebSocketConfig) *WebSocketConfig {
+	defaults := DefaultWebSocketConfig()
+	if config == nil {
+		return defaults
+	}
+	copied := *config
+	if copied.MaxMessageSize <= 0 {
+		copied.MaxMessageSize = defaults.MaxMessageSize
+	}
+	if copied.SendQueueSize <= 0 {
+		copied.SendQueueSize = defaults.SendQueueSize
+	}
+	return &copied
+}
+
+// WebSocketHandler handles an upgraded connection, the connection is closed when it
+// returns, normally when error is nil
+type WebSocketHandler func(conn *WebSocketConn) error
+
+// WebSocketRoute defines the websocket endpoint of a route
+type WebSocketRoute struct {
+	Config  *WebSocketConfig // nil uses DefaultWebSocketConfig
+	Handler WebSocketHandler
+}
+
+type webSocketMessage struct {
+	opcode  byte
+	payload []byte
+}
+
+// WebSocketConn defines an upgraded websocket connection, messages are read by
+// ReadMessage which also answers control frames, so handlers which only send must
+// keep reading to detect dead peers. Sent messages are queued and written in order
+// by the connection writer
+type WebSocketConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	config       *WebSocketConfig
+	request      *http.Request
+	subprotocol  string
+	ctx          context.Context
+	cancel       context.CancelFunc
+	send         chan webSocketMessage
+	closing      chan struct{}
+	done         chan struct{}
+	closePayload []byte
+	closeOnce    sync.Once
+	writeMux     sync.Mutex
+}
+
+// UpgradeWebSocket upgrades the request to websocket, errors are returned before
+// anything is written so they can be sent with SendError. Nil config uses
+// DefaultWebSocketConfig
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, config *WebSocketConfig) (*WebSocketConn, error) {
+	config = webSocketConfigOf(config)
+	if r.Method != http.MethodGet {
+		return nil, Error{http.StatusMethodNotAllowed, ErrorCodeMalformedMethod,
+			errors.New("websocket upgrade requires GET method")}
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, BadRequestError{errors.New("request is not a websocket upgrade")}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, Error{http.StatusUpgradeRequired, ErrorCodeBadRequest,
+			errors.New("unsupported websocket version")}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, BadRequestError{errors.New("invalid websocket key")}
+	}
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, ForbiddenError{errors.New("origin is not allowed")}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New(lib.StringTags("upgrade websocket", "response writer does not support hijacking"))
+	}
+	subprotocol := negotiateSubprotocol(r, config.Subprotocols)
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, lib.StringTags("upgrade websocket", "hijack"))
+	}
+	// deadlines of the http server no longer apply
+	conn.SetDeadline(time.Time{})
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n" +
+		"Connection: Upgrade\r\nSec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if config.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+	}
+	if _, err = io.WriteString(conn, response+"\r\n"); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, lib.StringTags("upgrade websocket", "write handshake"))
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &WebSocketConn{
+		conn:        conn,
+		reader:      rw.Reader,
+		config:      config,
+		request:     r,
+		subprotocol: subprotocol,
+		ctx:         ctx,
+		cancel:      cancel,
+		send:        make(chan webSocketMessage, config.SendQueueSize),
+		closing:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c, nil
+}
+
+// Request returns the upgraded request
+func (c *WebSocketConn) Request() *http.Request {
+	return c.request
+}
+
+// Subprotocol returns the negotiated subprotocol
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Context returns context of the request canceled once the connection is closing
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+// Done returns channel closed when the connection is closed
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Send queues message without blocking, ErrWebSocketQueueFull is returned when the
+// peer does not keep up
+func (c *WebSocketConn) Send(messageType int, data []byte) error {
+	select {
+	case <-c.closing:
+		return ErrWebSocketClosed
+	default:
+	}
+	select {
+	case c.send <- webSocketMessage{byte(messageType), data}:
+		return nil
+	default:
+		return ErrWebSocketQueueFull
+	}
+}
+
+// SendJSON queues v encoded as JSON text message
+func (c *WebSocketConn) SendJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("send json", "marshal"))
+	}
+	return c.Send(WebSocketTextMessage, data)
+}
+
+// Close writes queued messages and close frame then closes the connection, it waits
+// at most WriteTimeout for each frame
+func (c *WebSocketConn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	if code == WebSocketCloseNoStatus {
+		payload = payload[:0]
+	} else {
+		// control frame payload is limited to 125 bytes
+		if len(reason) > 123 {
+			reason = reason[:123]
+		}
+		payload = append(payload, reason...)
+	}
+	c.shutdown(payload)
+	<-c.done
+	return nil
+}
+
+// shutdown stops the writer, nil payload closes without close frame
+func (c *WebSocketConn) shutdown(payload []byte) {
+	c.closeOnce.Do(func() {
+		c.closePayload = payload
+		c.cancel()
+		close(c.closing)
+	})
+}
+
+// ReadMessage reads the next data message, pings are answered and a close frame is
+// answered then returned as *WebSocketCloseError
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		if c.config.ReadTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
+		}
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+		switch opcode {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := &WebSocketCloseError{Code: WebSocketCloseNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			c.Close(closeErr.Code, "")
+			return 0, nil, closeErr
+		case opText, opBinary:
+			if messageType != 0 {
+				return 0, nil, c.readFailed(errWebSocketProtocol)
+			}
+			messageType = int(opcode)
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.readFailed(errWebSocketProtocol)
+			}
+		default:
+			return 0, nil, c.readFailed(errWebSocketProtocol)
+		}
+		data = append(data, payload...)
+		if len(data) > c.config.MaxMessageSize {
+			return 0, nil, c.readFailed(errWebSocketTooBig)
+		}
+		if fin {
+			if messageType == WebSocketTextMessage && !utf8.Valid(data) {
+				c.Close(WebSocketCloseInvalidPayload, "invalid utf-8")
+				return 0, nil, ErrWebSocketClosed
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message into v
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(json.Unmarshal(data, v), lib.StringTags("read json", "unmarshal"))
+}
+
+// readFailed closes the connection with the close code of err
+func (c *WebSocketConn) readFailed(err error) error {
+	switch err {
+	case errWebSocketProtocol:
+		c.Close(WebSocketCloseProtocolError, err.Error())
+	case errWebSocketTooBig:
+		c.Close(WebSocketCloseMessageTooBig, err.Error())
+	default:
+		select {
+		case <-c.closing:
+			// reading is interrupted by closing connection
+			return ErrWebSocketClosed
+		default:
+		}
+		c.shutdown(nil)
+		return errors.Wrap(err, "read websocket")
+	}
+	return err
+}
+
+// readFrame reads a frame sent by client, client frames must be masked
+func (c *WebSocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(c.reader, header[:2]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		// no extension is negotiated
+		return fin, opcode, nil, errWebSocketProtocol
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.reader, header[:2]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.reader, header[:8]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(header[:8])
+		if length>>63 != 0 {
+			// the most significant bit must be 0
+			return fin, opcode, nil, errWebSocketProtocol
+		}
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		return fin, opcode, nil, errWebSocketProtocol
+	}
+	if length > uint64(c.config.MaxMessageSize) {
+		return fin, opcode, nil, errWebSocketTooBig
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes an unfragmented frame
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	frame := make([]byte, 2, 10+len(payload))
+	frame[0] = 0x80 | opcode
+	switch length := len(payload); {
+	case length < 126:
+		frame[1] = byte(length)
+	case length <= 0xffff:
+		frame[1] = 126
+		frame = frame[:4]
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame[1] = 127
+		frame = frame[:10]
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	frame = append(frame, payload...)
+	if c.config.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeLoop writes queued messages and pings until the connection is closing, then
+// flushes the queue and writes the close frame
+func (c *WebSocketConn) writeLoop() {
+	var ping <-chan time.Time
+	if c.config.PingInterval > 0 {
+		ticker := time.NewTicker(c.config.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	defer func() {
+		c.shutdown(nil)
+		c.conn.Close()
+		close(c.done)
+	}()
+	for {
+		select {
+		case message := <-c.send:
+			if err := c.writeFrame(message.opcode, message.payload); err != nil {
+				return
+			}
+		case <-ping:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		case <-c.closing:
+			if c.closePayload == nil {
+				return
+			}
+			for {
+				select {
+				case message := <-c.send:
+					if err := c.writeFrame(message.opcode, message.payload); err != nil {
+						return
+					}
+				default:
+					c.writeFrame(opClose, c.closePayload)
+					return
+				}
+			}
+		}
+	}
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContainsToken reports whether comma separated header values contain token
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func negotiateSubprotocol(r *http.Request, supported []string) string {
+	offered := map[string]bool{}
+	for _, value := range r.Header["Sec-Websocket-Protocol"] {
+		for _, part := range strings.Split(value, ",") {
+			offered[strings.TrimSpace(part)] = true
+		}
+	}
+	for _, protocol := range supported {
+		if offered[protocol] {
+			return protocol
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/hauxe/gom/broadcast"
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// TopicSubscriber subscribes handler to messages published on topic, e.g.
+// mqtt.Client
+type TopicSubscriber interface {
+	Subscribe(topic string, handler func(payload []byte)) error
+}
+
+// WebSocketHub fans out messages to subscribed connections, connections which do not
+// keep up with their send queue are closed
+type WebSocketHub struct {
+	// OnMessage handles messages read from subscribed connections, nil discards them
+	OnMessage func(conn *WebSocketConn, messageType int, data []byte)
+	conns     map[*WebSocketConn]struct{}
+	mux       sync.RWMutex
+}
+
+// NewWebSocketHub creates websocket hub
+func NewWebSocketHub() *WebSocketHub {
+	return &WebSocketHub{conns: make(map[*WebSocketConn]struct{})}
+}
+
+// Handler subscribes conn and reads it until it is closed, it is used as
+// WebSocketRoute handler
+func (h *WebSocketHub) Handler(conn *WebSocketConn) error {
+	h.Subscribe(conn)
+	defer h.Unsubscribe(conn)
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if h.OnMessage != nil {
+			h.OnMessage(conn, messageType, data)
+		}
+	}
+}
+
+// Subscribe adds conn to receivers of broadcast messages
+func (h *WebSocketHub) Subscribe(conn *WebSocketConn) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.conns[conn] = struct{}{}
+}
+
+// Unsubscribe removes conn from receivers of broadcast messages
+func (h *WebSocketHub) Unsubscribe(conn *WebSocketConn) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	delete(h.conns, conn)
+}
+
+// Len returns number of subscribed connections
+func (h *WebSocketHub) Len() int {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return len(h.conns)
+}
+
+// Broadcast queues message to every subscribed connection
+func (h *WebSocketHub) Broadcast(messageType int, data []byte) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	for conn := range h.conns {
+		if err := conn.Send(messageType, data); err == ErrWebSocketQueueFull {
+			go conn.Close(WebSocketClosePolicyViolation, err.Error())
+		}
+	}
+}
+
+// ListenBroadcaster broadcasts every value written to b until b is closed. Values
+// are encoded by encode, nil sends []byte as binary, string as text and other
+// values as JSON text messages
+func (h *WebSocketHub) ListenBroadcaster(b *broadcast.Broadcaster,
+	encode func(interface{}) (int, []byte, error)) error {
+	if encode == nil {
+		encode = encodeWebSocketMessage
+	}
+	receiver, err := b.Listen()
+	if err != nil {
+		return errors.Wrap(err, lib.StringTags("listen broadcaster", "listen"))
+	}
+	for {
+		v, err := receiver.Read()
+		if err != nil {
+			// broadcaster is closed
+			return nil
+		}
+		messageType, data, err := encode(v)
+		if err != nil {
+			return errors.Wrap(err, lib.StringTags("listen broadcaster", "encode"))
+		}
+		h.Broadcast(messageType, data)
+	}
+}
+
+// ListenTopic broadcasts messages published on topic, valid UTF-8 payloads are sent
+// as text and others as binary messages
+func (h *WebSocketHub) ListenTopic(subscriber TopicSubscriber, topic string) error {
+	err := subscriber.Subscribe(topic, func(payload []byte) {
+		messageType := WebSocketBinaryMessage
+		if utf8.Valid(payload) {
+			messageType = WebSocketTextMessage
+		}
+		h.Broadcast(messageType, payload)
+	})
+	return errors.Wrap(err, lib.StringTags("listen topic", topic))
+}
+
+func encodeWebSocketMessage(v interface{}) (int, []byte, error) {
+	switch data := v.(type) {
+	case []byte:
+		return WebSocketBinaryMessage, data, nil
+	case string:
+		return WebSocketTextMessage, []byte(data), nil
+	}
+	data, err := json.Marshal(v)
+	return WebSocketTextMessage, data, err
+}
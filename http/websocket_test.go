@@ -0,0 +1,165 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hauxe/gom/broadcast"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestAcceptKey(t *testing.T) {
+	t.Parallel()
+	// sample handshake of RFC 6455
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+	header := http.Header{"Connection": []string{"keep-alive, Upgrade"}}
+	require.True(t, headerContainsToken(header, "connection", "upgrade"))
+	require.False(t, headerContainsToken(header, "Upgrade", "websocket"))
+}
+
+func TestWebSocketConfig(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, DefaultWebSocketConfig(), webSocketConfigOf(nil))
+	config := &WebSocketConfig{PingInterval: time.Second}
+	filled := webSocketConfigOf(config)
+	require.Equal(t, 1<<20, filled.MaxMessageSize)
+	require.Equal(t, 64, filled.SendQueueSize)
+	require.Equal(t, time.Second, filled.PingInterval)
+	require.Zero(t, config.MaxMessageSize)
+}
+
+func TestWebSocketReadFrame(t *testing.T) {
+	t.Parallel()
+	frame := func(length []byte) *WebSocketConn {
+		data := append([]byte{0x82, 0x80 | 127}, length...)
+		return &WebSocketConn{
+			reader: bufio.NewReader(bytes.NewReader(append(data, 0, 0, 0, 0))),
+			config: webSocketConfigOf(&WebSocketConfig{MaxMessageSize: 16}),
+		}
+	}
+	_, _, _, err := frame([]byte{0x80, 0, 0, 0, 0, 0, 0, 1}).readFrame()
+	require.Equal(t, errWebSocketProtocol, err)
+	_, _, _, err = frame([]byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}).readFrame()
+	require.Equal(t, errWebSocketTooBig, err)
+	fin, opcode, payload, err := frame([]byte{0, 0, 0, 0, 0, 0, 0, 0}).readFrame()
+	require.Nil(t, err)
+	require.True(t, fin)
+	require.Equal(t, byte(opBinary), opcode)
+	require.Empty(t, payload)
+}
+
+func TestWebSocket(t *testing.T) {
+	config := &WebSocketConfig{
+		ReadTimeout:    300 * time.Millisecond,
+		WriteTimeout:   time.Second,
+		PingInterval:   100 * time.Millisecond,
+		MaxMessageSize: 1024,
+		SendQueueSize:  8,
+		Subprotocols:   []string{"chat"},
+	}
+	hub := NewWebSocketHub()
+	b := broadcast.NewBroadcaster()
+	defer b.Close()
+	server, err := CreateServer()
+	require.Nil(t, err)
+	err = server.Start(server.SetHostPortOption("localhost", 18013),
+		server.SetHandlerOption(
+			ServerRoute{Name: "echo", Method: http.MethodGet, Path: "/echo",
+				WebSocket: &WebSocketRoute{Config: config, Handler: func(conn *WebSocketConn) error {
+					for {
+						messageType, data, err := conn.ReadMessage()
+						if err != nil {
+							return err
+						}
+						if err = conn.Send(messageType, data); err != nil {
+							return err
+						}
+					}
+				}}},
+			ServerRoute{Name: "hub", Method: http.MethodGet, Path: "/hub",
+				WebSocket: &WebSocketRoute{Config: config, Handler: hub.Handler}},
+		))
+	require.Nil(t, err)
+	go hub.ListenBroadcaster(b, nil)
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1)
+	dial := func(path string, protocols ...string) *websocket.Conn {
+		wsConfig, err := websocket.NewConfig(wsURL+path, server.URL)
+		require.Nil(t, err)
+		wsConfig.Protocol = protocols
+		ws, err := websocket.DialConfig(wsConfig)
+		require.Nil(t, err)
+		return ws
+	}
+	receive := func(ws *websocket.Conn) (string, error) {
+		var message string
+		ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		err := websocket.Message.Receive(ws, &message)
+		return message, err
+	}
+
+	t.Run("not upgrade", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/echo")
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("echo", func(t *testing.T) {
+		ws := dial("/echo", "other", "chat")
+		defer ws.Close()
+		require.Nil(t, websocket.Message.Send(ws, "hello"))
+		message, err := receive(ws)
+		require.Nil(t, err)
+		require.Equal(t, "hello", message)
+	})
+	t.Run("keepalive", func(t *testing.T) {
+		ws := dial("/echo")
+		defer ws.Close()
+		// pings are answered while receiving, connection outlives read timeout
+		received := make(chan string)
+		go func() {
+			message, _ := receive(ws)
+			received <- message
+		}()
+		time.Sleep(3 * config.ReadTimeout)
+		require.Nil(t, websocket.Message.Send(ws, "still here"))
+		require.Equal(t, "still here", <-received)
+	})
+	t.Run("too big", func(t *testing.T) {
+		ws := dial("/echo")
+		defer ws.Close()
+		require.Nil(t, websocket.Message.Send(ws, strings.Repeat("a", 2048)))
+		_, err := receive(ws)
+		require.NotNil(t, err)
+	})
+	t.Run("hub", func(t *testing.T) {
+		first, second := dial("/hub"), dial("/hub")
+		defer first.Close()
+		defer second.Close()
+		for i := 0; i < 100 && hub.Len() < 2; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, 2, hub.Len())
+		b.Write(map[string]string{"news": "hello"})
+		for _, ws := range []*websocket.Conn{first, second} {
+			message, err := receive(ws)
+			require.Nil(t, err)
+			require.Equal(t, `{"news":"hello"}`, message)
+		}
+	})
+	t.Run("stop", func(t *testing.T) {
+		ws := dial("/hub")
+		defer ws.Close()
+		for i := 0; i < 100 && hub.Len() < 1; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Nil(t, server.Stop())
+		_, err := receive(ws)
+		require.NotNil(t, err)
+		require.Equal(t, 0, hub.Len())
+	})
+}
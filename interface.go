@@ -1,6 +1,10 @@
 package sdk
 
-import "go.uber.org/zap/zapcore"
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+)
 
 // Server interface defines server functions
 type Server interface {
@@ -14,6 +18,11 @@ type Client interface {
 	Disconnect() error
 }
 
+// HealthChecker interface defines dependency health check
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
 // Sender interface defines message sender
 type Sender interface {
 	Send(msg []byte, to string) error
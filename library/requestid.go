@@ -0,0 +1,56 @@
+package library
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// HeaderRequestID is the header carrying request id across services
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds accepted request ids
+const maxRequestIDLength = 128
+
+type contextRequestID string
+
+const contextRequestIDKey contextRequestID = "request_id"
+
+// NewRequestID generates a random request id
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID reports whether the request id received from a caller is safe
+// to log and forward, it only accepts visible ascii characters
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithRequestID returns context carrying request id
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextRequestIDKey, id)
+}
+
+// RequestIDFromContext returns request id of the context, empty if it has none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextRequestIDKey).(string)
+	return id
+}
@@ -0,0 +1,30 @@
+package library
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+	id := NewRequestID()
+	require.Len(t, id, 32)
+	require.NotEqual(t, id, NewRequestID())
+	require.True(t, ValidRequestID(id))
+
+	ctx := context.Background()
+	require.Empty(t, RequestIDFromContext(ctx))
+	require.Equal(t, id, RequestIDFromContext(ContextWithRequestID(ctx, id)))
+}
+
+func TestValidRequestID(t *testing.T) {
+	t.Parallel()
+	require.True(t, ValidRequestID("req-1:abc_DEF"))
+	require.False(t, ValidRequestID(""))
+	require.False(t, ValidRequestID("has space"))
+	require.False(t, ValidRequestID("line\nbreak"))
+	require.False(t, ValidRequestID(strings.Repeat("a", 129)))
+}
@@ -0,0 +1,253 @@
+package library
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// client certificate verification modes
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request" // verifies certificate if the client sends one
+	ClientAuthRequire = "require" // requires a verified certificate
+)
+
+type contextClientIdentity string
+
+const contextClientIdentityKey contextClientIdentity = "client_identity"
+
+// TLSConfig defines server certificate and client certificate authentication
+// shared by http and grpc servers
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ReloadInterval checks certificate files for changes, zero disables reloading
+	ReloadInterval time.Duration
+	// ClientCAFile is the CA bundle verifying client certificates
+	ClientCAFile string
+	// ClientAuth is ClientAuthNone by default, ClientAuthRequest or ClientAuthRequire
+	ClientAuth string
+	// AllowedSubjects are common names or subject alternative names of allowed
+	// clients, empty allows every verified client
+	AllowedSubjects []string
+	// OnReloadError is called when changed files can not be loaded, the previous
+	// certificate keeps being served
+	OnReloadError func(error)
+}
+
+// ClientIdentity defines the verified client certificate of a connection
+type ClientIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	SerialNumber   string
+	Certificate    *x509.Certificate
+}
+
+// NewServerTLSConfig creates server tls config, the returned reloader serves the
+// certificate and must be closed when the server stops
+func NewServerTLSConfig(config *TLSConfig) (*tls.Config, *CertificateReloader, error) {
+	reloader, err := NewCertificateReloader(config.CertFile, config.KeyFile,
+		config.ReloadInterval, config.OnReloadError)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, StringTags("server tls config", "certificate"))
+	}
+	tlsConfig := &tls.Config{GetCertificate: reloader.GetCertificate}
+	switch config.ClientAuth {
+	case "", ClientAuthNone:
+		return tlsConfig, reloader, nil
+	case ClientAuthRequest:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		reloader.Close()
+		return nil, nil, errors.New(StringTags("server tls config", "unknown client auth", config.ClientAuth))
+	}
+	pem, err := ioutil.ReadFile(config.ClientCAFile)
+	if err != nil {
+		reloader.Close()
+		return nil, nil, errors.Wrap(err, StringTags("server tls config", "read client ca"))
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		reloader.Close()
+		return nil, nil, errors.New(StringTags("server tls config", "client ca has no certificate"))
+	}
+	if len(config.AllowedSubjects) > 0 {
+		allowed := make(map[string]bool, len(config.AllowedSubjects))
+		for _, subject := range config.AllowedSubjects {
+			allowed[subject] = true
+		}
+		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			if len(chains) == 0 {
+				// no certificate is sent in request mode
+				return nil
+			}
+			if !subjectAllowed(chains[0][0], allowed) {
+				return errors.New(StringTags("verify client", "subject is not allowed",
+					chains[0][0].Subject.CommonName))
+			}
+			return nil
+		}
+	}
+	return tlsConfig, reloader, nil
+}
+
+func subjectAllowed(cert *x509.Certificate, allowed map[string]bool) bool {
+	if allowed[cert.Subject.CommonName] {
+		return true
+	}
+	for _, names := range [][]string{cert.DNSNames, cert.EmailAddresses} {
+		for _, name := range names {
+			if allowed[name] {
+				return true
+			}
+		}
+	}
+	for _, uri := range cert.URIs {
+		if allowed[uri.String()] {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIdentityOf returns identity of the verified client certificate, nil if the
+// client is not verified
+func ClientIdentityOf(state *tls.ConnectionState) *ClientIdentity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := state.VerifiedChains[0][0]
+	identity := &ClientIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		SerialNumber:   cert.SerialNumber.String(),
+		Certificate:    cert,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity
+}
+
+// ContextWithClientIdentity returns context carrying client identity
+func ContextWithClientIdentity(ctx context.Context, identity *ClientIdentity) context.Context {
+	return context.WithValue(ctx, contextClientIdentityKey, identity)
+}
+
+// ClientIdentityFromContext returns client identity of the context, nil if it has none
+func ClientIdentityFromContext(ctx context.Context) *ClientIdentity {
+	if ctx == nil {
+		return nil
+	}
+	identity, _ := ctx.Value(contextClientIdentityKey).(*ClientIdentity)
+	return identity
+}
+
+// CertificateReloader serves a certificate reloaded when its files change
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	onError  func(error)
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+	mux      sync.RWMutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCertificateReloader loads certificate and checks its files for changes every
+// interval, zero interval never reloads
+func NewCertificateReloader(certFile, keyFile string, interval time.Duration,
+	onError func(error)) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		onError:  onError,
+		stop:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go r.watch(interval)
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, it is used as
+// tls.Config.GetCertificate
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads certificate files, the current certificate is kept on error
+func (r *CertificateReloader) Reload() error {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, StringTags("reload certificate", "load key pair"))
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.cert = &cert
+	r.modTimes = modTimes
+	return nil
+}
+
+// Close stops watching certificate files
+func (r *CertificateReloader) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	return nil
+}
+
+func (r *CertificateReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			modTimes, err := r.fileModTimes()
+			r.mux.RLock()
+			changed := err != nil || modTimes != r.modTimes
+			r.mux.RUnlock()
+			if !changed {
+				continue
+			}
+			if err = r.Reload(); err != nil && r.onError != nil {
+				r.onError(err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *CertificateReloader) fileModTimes() (modTimes [2]time.Time, err error) {
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, errors.Wrap(err, StringTags("reload certificate", "stat", file))
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
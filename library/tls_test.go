@@ -0,0 +1,151 @@
+package library
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSelfSigned(t *testing.T, dir, name string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.Nil(t, ioutil.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.Nil(t, ioutil.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, r *CertificateReloader) string {
+	cert, err := r.GetCertificate(nil)
+	require.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.Nil(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReloader(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "tls")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	_, err = NewCertificateReloader(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing.pem"), 0, nil)
+	require.NotNil(t, err)
+
+	certFile, keyFile := writeSelfSigned(t, dir, "first")
+	reloadErrors := make(chan error, 10)
+	r, err := NewCertificateReloader(certFile, keyFile, 20*time.Millisecond, func(err error) {
+		reloadErrors <- err
+	})
+	require.Nil(t, err)
+	defer r.Close()
+	require.Equal(t, "first", commonName(t, r))
+
+	// invalid files keep the previous certificate
+	future := time.Now().Add(time.Minute)
+	require.Nil(t, ioutil.WriteFile(certFile, []byte("invalid"), 0600))
+	require.Nil(t, os.Chtimes(certFile, future, future))
+	select {
+	case err := <-reloadErrors:
+		require.NotNil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("reload error is not reported")
+	}
+	require.Equal(t, "first", commonName(t, r))
+
+	writeSelfSigned(t, dir, "second")
+	future = future.Add(time.Minute)
+	require.Nil(t, os.Chtimes(certFile, future, future))
+	require.Nil(t, os.Chtimes(keyFile, future, future))
+	for i := 0; i < 100 && commonName(t, r) != "second"; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, "second", commonName(t, r))
+	require.Nil(t, r.Close())
+	require.Nil(t, r.Close())
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "tls")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeSelfSigned(t, dir, "server")
+
+	tlsConfig, r, err := NewServerTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.Nil(t, err)
+	r.Close()
+	require.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+	require.NotNil(t, tlsConfig.GetCertificate)
+
+	_, _, err = NewServerTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"})
+	require.NotNil(t, err)
+	_, _, err = NewServerTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile,
+		ClientAuth: ClientAuthRequire})
+	require.NotNil(t, err)
+	_, _, err = NewServerTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile,
+		ClientAuth: ClientAuthRequire, ClientCAFile: keyFile})
+	require.NotNil(t, err)
+
+	tlsConfig, r, err = NewServerTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile,
+		ClientAuth: ClientAuthRequest, ClientCAFile: certFile, AllowedSubjects: []string{"alice"}})
+	require.Nil(t, err)
+	r.Close()
+	require.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	require.Nil(t, tlsConfig.VerifyPeerCertificate(nil, nil))
+	alice := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	mallory := &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}}
+	require.Nil(t, tlsConfig.VerifyPeerCertificate(nil, [][]*x509.Certificate{{alice}}))
+	require.NotNil(t, tlsConfig.VerifyPeerCertificate(nil, [][]*x509.Certificate{{mallory}}))
+}
+
+func TestSubjectAllowed(t *testing.T) {
+	t.Parallel()
+	allowed := map[string]bool{"svc.local": true, "spiffe://cluster/svc": true, "me@example.com": true}
+	spiffe, err := url.Parse("spiffe://cluster/svc")
+	require.Nil(t, err)
+	require.True(t, subjectAllowed(&x509.Certificate{DNSNames: []string{"svc.local"}}, allowed))
+	require.True(t, subjectAllowed(&x509.Certificate{EmailAddresses: []string{"me@example.com"}}, allowed))
+	require.True(t, subjectAllowed(&x509.Certificate{URIs: []*url.URL{spiffe}}, allowed))
+	require.False(t, subjectAllowed(&x509.Certificate{Subject: pkix.Name{CommonName: "other"}}, allowed))
+}
+
+func TestClientIdentity(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, ClientIdentityOf(nil))
+	require.Nil(t, ClientIdentityOf(&tls.ConnectionState{}))
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}, SerialNumber: big.NewInt(42)}
+	identity := ClientIdentityOf(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}})
+	require.Equal(t, "alice", identity.CommonName)
+	require.Equal(t, "42", identity.SerialNumber)
+
+	ctx := context.Background()
+	require.Nil(t, ClientIdentityFromContext(ctx))
+	require.Equal(t, identity, ClientIdentityFromContext(ContextWithClientIdentity(ctx, identity)))
+}
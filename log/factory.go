@@ -18,6 +18,7 @@ import (
 	"context"
 
 	sdk "github.com/hauxe/gom"
+	lib "github.com/hauxe/gom/library"
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -43,13 +44,18 @@ func (b Factory) Bg() sdk.Logger {
 
 // For returns a context-aware Logger. If the context
 // contains an OpenTracing span, all logging calls are also
-// echo-ed into the span.
+// echo-ed into the span. The request id of the context is
+// added to every entry.
 func (b Factory) For(ctx context.Context) sdk.Logger {
+	zl := b.Logger
+	if requestID := lib.RequestIDFromContext(ctx); requestID != "" {
+		zl = zl.With(zap.String("request_id", requestID))
+	}
 	if span := opentracing.SpanFromContext(ctx); span != nil {
 		// TODO for Jaeger span extract trace/span IDs as fields
-		return spanLogger{span: span, Logger: b.Logger}
+		return spanLogger{span: span, Logger: zl}
 	}
-	return b.Bg()
+	return logger{Logger: zl}
 }
 
 // With creates a child logger, and optionally adds some context fields to that logger.
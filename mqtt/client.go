@@ -9,6 +9,7 @@ import (
 
 	mq "github.com/eclipse/paho.mqtt.golang"
 	lib "github.com/hauxe/gom/library"
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
@@ -82,6 +83,14 @@ func (c *Client) Disconnect() error {
 	return nil
 }
 
+// HealthCheck checks mqtt connection status
+func (c *Client) HealthCheck(_ context.Context) error {
+	if c.C == nil || !c.C.IsConnected() {
+		return errors.New(lib.StringTags("health check", "client is not connected"))
+	}
+	return nil
+}
+
 // SetAuthOption set mqtt auth
 func (c *Client) SetAuthOption(username, password string) ConnectClientOptions {
 	return func() (err error) {
@@ -108,12 +117,15 @@ func (c *Client) SetTracerOption(tracer *trace.Client) ConnectClientOptions {
 	}
 }
 
-// Send send a message to channel
+// Send send a message to channel, mqtt 3.1.1 has no message header so the
+// context request id is only recorded on the trace span
 func (c *Client) Send(ctx context.Context, msg []byte, to string) (err error) {
 	if c.TraceClient != nil {
-		ctx, err = c.TraceClient.StartTracing(ctx,
-			trace.Tag("msg", string(msg)),
-			trace.Tag("to", to))
+		tags := []opentracing.StartSpanOption{trace.Tag("msg", string(msg)), trace.Tag("to", to)}
+		if requestID := lib.RequestIDFromContext(ctx); requestID != "" {
+			tags = append(tags, trace.Tag("request_id", requestID))
+		}
+		ctx, err = c.TraceClient.StartTracing(ctx, tags...)
 		if err != nil {
 			return errors.Wrap(err, lib.StringTags("client send", "trace error"))
 		}
@@ -125,3 +137,22 @@ func (c *Client) Send(ctx context.Context, msg []byte, to string) (err error) {
 	}
 	return nil
 }
+
+// Subscribe calls handler with payload of every message published on topic
+func (c *Client) Subscribe(topic string, handler func(payload []byte)) error {
+	token := c.C.Subscribe(topic, 0, func(_ mq.Client, msg mq.Message) {
+		handler(msg.Payload())
+	})
+	if token.Wait() && token.Error() != nil {
+		return errors.Wrap(token.Error(), lib.StringTags("subscribe", topic))
+	}
+	return nil
+}
+
+// Unsubscribe stops receiving messages published on topic
+func (c *Client) Unsubscribe(topic string) error {
+	if token := c.C.Unsubscribe(topic); token.Wait() && token.Error() != nil {
+		return errors.Wrap(token.Error(), lib.StringTags("unsubscribe", topic))
+	}
+	return nil
+}
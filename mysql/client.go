@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -93,3 +94,14 @@ func (c *Client) Disconnect() error {
 	}
 	return nil
 }
+
+// HealthCheck pings the database
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.C == nil {
+		return errors.New(lib.StringTags("health check", "client is not connected"))
+	}
+	if err := c.C.PingContext(ctx); err != nil {
+		return errors.Wrap(err, lib.StringTags("health check", "ping database"))
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package pool
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hauxe/gom/environment"
@@ -13,6 +15,8 @@ import (
 
 const (
 	maxWorkers = 1000
+	// drainPollInterval is how often Drain checks for active jobs
+	drainPollInterval = 10 * time.Millisecond
 )
 
 // WorkerConfig defines pool properties
@@ -28,6 +32,8 @@ type Worker struct {
 	quit       chan struct{}
 	isStopped  bool
 	mux        sync.RWMutex
+	active     int64
+	stopOnce   sync.Once
 }
 
 // CreateWorker create a worker pool
@@ -83,14 +89,8 @@ func (w *Worker) StartServer(options ...func() error) (err error) {
 
 				select {
 				case job := <-jobChannel:
-					if job == nil {
-						w.ErrorLog(job, errors.New("job is nil"))
-						continue
-					}
 					// we have received a work request.
-					if err := job.Execute(); err != nil {
-						w.ErrorLog(job, err)
-					}
+					w.execute(job)
 
 				case <-w.quit:
 					// we have received a signal to stop
@@ -115,7 +115,42 @@ func (w *Worker) StartServer(options ...func() error) (err error) {
 
 // StopServer signals the worker to stop listening for work requests.
 func (w *Worker) StopServer() {
-	close(w.quit)
+	w.stopOnce.Do(func() {
+		close(w.quit)
+	})
+}
+
+// Drain waits until all queued jobs are executed or the context is done
+func (w *Worker) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if w.ActiveJobs() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "drain worker with %d active jobs", w.ActiveJobs())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ActiveJobs returns the number of jobs handed to workers which are not finished,
+// the pool has no queue so callers of QueueJob wait for a free worker
+func (w *Worker) ActiveJobs() int64 {
+	return atomic.LoadInt64(&w.active)
+}
+
+func (w *Worker) execute(job Job) {
+	defer atomic.AddInt64(&w.active, -1)
+	if job == nil {
+		w.Logger.Bg().Error("worker job error", zap.Error(errors.New("job is nil")))
+		return
+	}
+	if err := job.Execute(); err != nil {
+		w.ErrorLog(job, err)
+	}
 }
 
 // QueueJob queue a job with timeout
@@ -127,9 +162,10 @@ func (w *Worker) QueueJob(job Job, timeout time.Duration) (err error) {
 	select {
 	case jobChannel, ok := <-w.WorkerPool:
 		if ok {
+			atomic.AddInt64(&w.active, 1)
 			jobChannel <- job
 		} else {
-			err = errors.Errorf("worker channel is closed unexpectedly", timeout)
+			err = errors.New("worker channel is closed unexpectedly")
 		}
 	case <-t:
 		err = errors.Errorf("wait for worker timedout after %d", timeout)
@@ -155,3 +155,52 @@ func TestStartWorker(t *testing.T) {
 		worker.StopServer()
 	})
 }
+
+func TestDrainWorker(t *testing.T) {
+	t.Parallel()
+	t.Run("error context done", func(t *testing.T) {
+		t.Parallel()
+		worker, err := CreateWorker()
+		require.Nil(t, err)
+		require.NotNil(t, worker)
+		err = worker.StartServer(worker.SetMaxWorkersOption(1))
+		require.Nil(t, err)
+		defer worker.StopServer()
+		release := make(chan struct{})
+		j := job{name: "blocked job", f: func() { <-release }}
+		require.Nil(t, worker.QueueJob(&j, time.Second))
+		require.Equal(t, int64(1), worker.ActiveJobs())
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.Error(t, worker.Drain(ctx))
+		close(release)
+		require.Nil(t, worker.Drain(context.Background()))
+		require.Zero(t, worker.ActiveJobs())
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		numJob := 5
+		worker, err := CreateWorker()
+		require.Nil(t, err)
+		require.NotNil(t, worker)
+		err = worker.StartServer(worker.SetMaxWorkersOption(numJob))
+		require.Nil(t, err)
+		var counter int32
+		for i := 0; i < numJob; i++ {
+			j := job{
+				name: "job: " + lib.ToString(i),
+				f: func() {
+					time.Sleep(50 * time.Millisecond)
+					atomic.AddInt32(&counter, 1)
+				},
+			}
+			require.Nil(t, worker.QueueJob(&j, time.Second))
+		}
+		require.Nil(t, worker.Drain(context.Background()))
+		require.Equal(t, int32(numJob), atomic.LoadInt32(&counter))
+		worker.StopServer()
+		// stop twice is safe
+		worker.StopServer()
+	})
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithm type indicates rate limit algorithm
+type Algorithm int
+
+// defines rate limit algorithms
+const (
+	// TokenBucket allows bursts up to Limit and refills Limit tokens every Window
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Limit requests in any Window, it weights the previous
+	// fixed window count by its overlap with the sliding window
+	SlidingWindow
+)
+
+// Policy defines how many requests a key is allowed
+type Policy struct {
+	Algorithm Algorithm
+	Limit     int
+	Window    time.Duration
+}
+
+// Validate checks policy values
+func (p Policy) Validate() error {
+	if p.Limit <= 0 {
+		return errors.New("rate limit must be positive")
+	}
+	if p.Window <= 0 {
+		return errors.New("rate limit window must be positive")
+	}
+	if p.Algorithm != TokenBucket && p.Algorithm != SlidingWindow {
+		return errors.Errorf("unknown rate limit algorithm %d", p.Algorithm)
+	}
+	return nil
+}
+
+// Result defines rate limit decision of a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the quota is fully restored
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps rate limit state of keys
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// rate returns tokens refilled per nanosecond
+func rate(policy Policy) float64 {
+	return float64(policy.Limit) / float64(policy.Window)
+}
+
+// takeToken refills tokens for the elapsed time then takes one if available
+func takeToken(policy Policy, tokens float64, elapsed time.Duration) (float64, bool) {
+	if elapsed > 0 {
+		tokens = math.Min(float64(policy.Limit), tokens+float64(elapsed)*rate(policy))
+	}
+	if tokens >= 1 {
+		return tokens - 1, true
+	}
+	return tokens, false
+}
+
+func tokenBucketResult(policy Policy, tokens float64, allowed bool) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(policy.Limit) - tokens) / rate(policy))),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate(policy)))
+	}
+	return result
+}
+
+// windowStart returns start of the fixed window containing now
+func windowStart(policy Policy, now time.Time) time.Time {
+	return time.Unix(0, now.UnixNano()-now.UnixNano()%int64(policy.Window))
+}
+
+// slidingCount weights the previous window count by its overlap with the sliding window
+func slidingCount(policy Policy, previous, current int64, elapsed time.Duration) float64 {
+	weight := float64(policy.Window-elapsed) / float64(policy.Window)
+	return float64(previous)*weight + float64(current)
+}
+
+func slidingWindowResult(policy Policy, previous, current int64, elapsed time.Duration,
+	allowed bool) Result {
+	count := slidingCount(policy, previous, current, elapsed)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Max(0, math.Floor(float64(policy.Limit)-count))),
+		// both windows are forgotten after the next window ends
+		Reset: 2*policy.Window - elapsed,
+	}
+	if current == 0 && previous == 0 {
+		result.Reset = 0
+	}
+	if allowed {
+		return result
+	}
+	result.RetryAfter = policy.Window - elapsed
+	if current < int64(policy.Limit) && previous > 0 {
+		// wait until the previous window weight drops enough for one request
+		excess := float64(previous+current) - float64(policy.Limit) + 1
+		wait := time.Duration(math.Ceil(excess/float64(previous)*float64(policy.Window))) - elapsed
+		if wait < result.RetryAfter {
+			result.RetryAfter = wait
+		}
+	}
+	if result.RetryAfter <= 0 {
+		result.RetryAfter = time.Nanosecond
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func createMemoryStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	store := NewMemoryStore()
+	store.Now = clock.Now
+	return store, clock
+}
+
+func TestPolicyValidate(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, Policy{Limit: 1, Window: time.Second}.Validate())
+	require.Error(t, Policy{Window: time.Second}.Validate())
+	require.Error(t, Policy{Limit: 1}.Validate())
+	require.Error(t, Policy{Algorithm: Algorithm(5), Limit: 1, Window: time.Second}.Validate())
+	store, _ := createMemoryStore()
+	_, err := store.Take(context.Background(), "key", Policy{})
+	require.Error(t, err)
+}
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	t.Parallel()
+	store, clock := createMemoryStore()
+	ctx := context.Background()
+	policy := Policy{Algorithm: TokenBucket, Limit: 2, Window: 2 * time.Second}
+
+	result, err := store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, result)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.Equal(t, 2*time.Second, result.Reset)
+
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, time.Second, result.RetryAfter)
+
+	// other keys have their own bucket
+	result, err = store.Take(ctx, "b", policy)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+
+	clock.Add(500 * time.Millisecond)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 500*time.Millisecond, result.RetryAfter)
+
+	clock.Add(500 * time.Millisecond)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+
+	// bucket never exceeds its capacity
+	clock.Add(time.Hour)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, result.Remaining)
+	require.Len(t, store.entries, 1)
+}
+
+func TestMemoryStoreSlidingWindow(t *testing.T) {
+	t.Parallel()
+	store, clock := createMemoryStore()
+	ctx := context.Background()
+	policy := Policy{Algorithm: SlidingWindow, Limit: 4, Window: time.Second}
+
+	for i := 3; i >= 0; i-- {
+		result, err := store.Take(ctx, "a", policy)
+		require.Nil(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, i, result.Remaining)
+	}
+	clock.Add(250 * time.Millisecond)
+	result, err := store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 750*time.Millisecond, result.RetryAfter)
+
+	// previous window weighs 75%, 3 requests are counted
+	clock.Add(time.Second)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.False(t, result.Allowed)
+	// previous weight has to drop to 50%
+	require.Equal(t, 250*time.Millisecond, result.RetryAfter)
+
+	clock.Add(250 * time.Millisecond)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+
+	// windows older than the previous one are forgotten
+	clock.Add(3 * time.Second)
+	result, err = store.Take(ctx, "a", policy)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 3, result.Remaining)
+}
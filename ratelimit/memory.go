@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lib "github.com/hauxe/gom/library"
+	"github.com/pkg/errors"
+)
+
+// sweepInterval is how often idle keys are removed from memory store
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	// token bucket state
+	tokens float64
+	last   time.Time
+	// sliding window state
+	window   time.Time
+	previous int64
+	current  int64
+	expires  time.Time
+}
+
+// MemoryStore keeps rate limit state in process memory, limits are not shared
+// between server instances
+type MemoryStore struct {
+	// Now returns current time, it is replaceable for testing
+	Now       func() time.Time
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+	mux       sync.Mutex
+}
+
+// NewMemoryStore creates memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Now:     time.Now,
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+// Take takes one request from the key quota
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	if err := policy.Validate(); err != nil {
+		return Result{}, errors.Wrap(err, lib.StringTags("take", key))
+	}
+	now := s.Now()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.sweep(now)
+	entry, existed := s.entries[key]
+	if !existed {
+		entry = &memoryEntry{tokens: float64(policy.Limit), last: now}
+		s.entries[key] = entry
+	}
+	if policy.Algorithm == SlidingWindow {
+		return s.takeSlidingWindow(entry, policy, now), nil
+	}
+	var allowed bool
+	entry.tokens, allowed = takeToken(policy, entry.tokens, now.Sub(entry.last))
+	entry.last = now
+	// an idle bucket is full again after one window
+	entry.expires = now.Add(policy.Window)
+	return tokenBucketResult(policy, entry.tokens, allowed), nil
+}
+
+func (s *MemoryStore) takeSlidingWindow(entry *memoryEntry, policy Policy, now time.Time) Result {
+	start := windowStart(policy, now)
+	switch {
+	case start.Equal(entry.window):
+	case start.Sub(entry.window) == policy.Window:
+		entry.previous, entry.current = entry.current, 0
+	default:
+		entry.previous, entry.current = 0, 0
+	}
+	entry.window = start
+	elapsed := now.Sub(start)
+	allowed := slidingCount(policy, entry.previous, entry.current, elapsed)+1 <= float64(policy.Limit)
+	if allowed {
+		entry.current++
+	}
+	entry.expires = start.Add(2 * policy.Window)
+	return slidingWindowResult(policy, entry.previous, entry.current, elapsed, allowed)
+}
+
+// sweep removes expired keys, caller must hold mux
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	lib "github.com/hauxe/gom/library"
+	sdkredis "github.com/hauxe/gom/redis"
+	"github.com/pkg/errors"
+)
+
+// tokenScale keeps token fraction when redis converts lua numbers to integers
+const tokenScale = 1000000
+
+// tokenBucketScript refills and takes a token atomically
+// KEYS[1] bucket, ARGV limit, window ms, now ms
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+if now > ts then
+  tokens = math.min(limit, tokens + (now - ts) * limit / window)
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', math.max(now, ts))
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, math.floor(tokens * ` + strconv.Itoa(tokenScale) + `)}
+`)
+
+// slidingWindowScript counts a request in the current window if allowed
+// KEYS[1] current window, KEYS[2] previous window, ARGV limit, window ms, elapsed ms
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local allowed = 0
+if previous * (window - elapsed) / window + current + 1 <= limit then
+  current = redis.call('INCR', KEYS[1])
+  redis.call('PEXPIRE', KEYS[1], window * 2)
+  allowed = 1
+end
+return {allowed, previous, current}
+`)
+
+// RedisStore keeps rate limit state in redis so limits are shared between
+// server instances, time is taken from the caller so servers should be in sync
+type RedisStore struct {
+	Client *sdkredis.Client
+	// Prefix is prepended to every key
+	Prefix string
+	// Now returns current time, it is replaceable for testing
+	Now func() time.Time
+}
+
+// NewRedisStore creates redis store on a connected client
+func NewRedisStore(client *sdkredis.Client, prefix string) *RedisStore {
+	return &RedisStore{Client: client, Prefix: prefix, Now: time.Now}
+}
+
+// Take takes one request from the key quota
+func (s *RedisStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	if err := policy.Validate(); err != nil {
+		return Result{}, errors.Wrap(err, lib.StringTags("take", key))
+	}
+	if s.Client == nil || s.Client.C == nil {
+		return Result{}, errors.New(lib.StringTags("take", key, "redis client is not connected"))
+	}
+	client := s.Client.C.WithContext(ctx)
+	now := s.Now()
+	window := int64(policy.Window / time.Millisecond)
+	if window <= 0 {
+		window = 1
+	}
+	if policy.Algorithm == SlidingWindow {
+		start := windowStart(policy, now)
+		elapsed := now.Sub(start)
+		index := start.UnixNano() / int64(policy.Window)
+		values, err := s.run(client, slidingWindowScript, []string{
+			s.Prefix + key + ":" + strconv.FormatInt(index, 10),
+			s.Prefix + key + ":" + strconv.FormatInt(index-1, 10),
+		}, policy.Limit, window, int64(elapsed/time.Millisecond))
+		if err != nil {
+			return Result{}, errors.Wrap(err, lib.StringTags("take", key))
+		}
+		return slidingWindowResult(policy, values[1], values[2], elapsed, values[0] == 1), nil
+	}
+	values, err := s.run(client, tokenBucketScript, []string{s.Prefix + key},
+		policy.Limit, window, now.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return Result{}, errors.Wrap(err, lib.StringTags("take", key))
+	}
+	tokens := float64(values[1]) / tokenScale
+	return tokenBucketResult(policy, tokens, values[0] == 1), nil
+}
+
+// run runs the script and returns its integer results
+func (s *RedisStore) run(client *redis.Client, script *redis.Script, keys []string,
+	args ...interface{}) ([]int64, error) {
+	reply, err := script.Run(client, keys, args...).Result()
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("unexpected script reply %v", reply)
+	}
+	values := make([]int64, len(items))
+	for i, item := range items {
+		if values[i], ok = item.(int64); !ok {
+			return nil, errors.Errorf("unexpected script reply %v", reply)
+		}
+	}
+	return values, nil
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"strings"
 
 	"github.com/hauxe/gom/environment"
@@ -73,6 +74,17 @@ func (c *Client) Disconnect() (err error) {
 	return nil
 }
 
+// HealthCheck sends redis PING
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.C == nil {
+		return errors.New(lib.StringTags("health check", "client is not connected"))
+	}
+	if err := c.C.WithContext(ctx).Ping().Err(); err != nil {
+		return errors.Wrap(err, lib.StringTags("health check", "ping client"))
+	}
+	return nil
+}
+
 // SetAuthOption set redis auth
 func (c *Client) SetAuthOption(password string) ConnectClientOptions {
 	return func() (err error) {